@@ -9,7 +9,10 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -118,9 +121,10 @@ func (x createIssueParams) isValid() bool {
 	return x.title != "" && x.body != ""
 }
 
-func (s repoAPI) createIssue(r *Repo, arg createIssueParams) (string, error) {
+// createIssue creates a new issue and returns its number and URL.
+func (s repoAPI) createIssue(r *Repo, arg createIssueParams) (int, string, error) {
 	if !r.isValid() || !arg.isValid() {
-		return "", fmt.Errorf("createIssue: %+v: %+v: %w", r, arg, ErrInvalidArguments)
+		return 0, "", fmt.Errorf("createIssue: %+v: %+v: %w", r, arg, ErrInvalidArguments)
 	}
 	switch r.Vendor {
 	case gitLab:
@@ -128,95 +132,544 @@ func (s repoAPI) createIssue(r *Repo, arg createIssueParams) (string, error) {
 	case gitHub:
 		return s.gitHubCreateIssue(r, arg)
 	}
-	return "", ErrInvalidArguments
+	return 0, "", ErrInvalidArguments
 }
 
-func (s repoAPI) gitLabCreateIssue(r *Repo, arg createIssueParams) (string, error) {
-	wrapErr := func(err error) error {
-		return fmt.Errorf("gitLabCreateIssue: %+v: %w", arg, err)
+func (s repoAPI) gitLabCreateIssue(r *Repo, arg createIssueParams) (int, string, error) {
+	v := url.Values{
+		"title":       {arg.title},
+		"description": {arg.body},
+	}
+	if len(arg.labels) > 0 {
+		v.Set("labels", strings.Join(arg.labels, ","))
 	}
-	u, err := url.JoinPath(gitLabBaseURL, "projects", url.PathEscape(r.Owner+"/"+r.Repo), "issues")
+	// long descriptions do not fit into the URL
+	data, err := s.gitLabPostForm(r, v, "projects", gitLabProjectID(r), "issues")
 	if err != nil {
-		return "", wrapErr(err)
+		return 0, "", fmt.Errorf("gitLabCreateIssue: %+v: %w", arg, err)
 	}
-	v := url.Values{
-		"private_token": {r.Token},
-		"title":         {arg.title},
-		"description":   {arg.body},
+	info, _ := data.(map[string]any)
+	htmlURL, _ := info["web_url"].(string)
+	number, _ := info["iid"].(float64)
+	return int(number), htmlURL, nil
+}
+
+func (s repoAPI) gitHubCreateIssue(r *Repo, arg createIssueParams) (int, string, error) {
+	params := map[string]any{
+		"title": arg.title,
+		"body":  arg.body,
 	}
 	if len(arg.labels) > 0 {
-		v.Set("labels", strings.Join(arg.labels, ","))
+		params["labels"] = arg.labels
 	}
-	res, err := http.Post(u+"?"+v.Encode(), "application/json", nil)
+	data, err := s.gitHubRequest(r, "POST", params, "repos", r.Owner, r.Repo, "issues")
 	if err != nil {
-		return "", wrapErr(err)
+		return 0, "", fmt.Errorf("gitHubCreateIssue: %+v: %w", arg, err)
+	}
+	info, _ := data.(map[string]any)
+	htmlURL, _ := info["html_url"].(string)
+	number, _ := info["number"].(float64)
+	return int(number), htmlURL, nil
+}
+
+// createComment adds a new comment to an existing issue.
+func (s repoAPI) createComment(r *Repo, number int, body string) error {
+	if !r.isValid() || number == 0 || body == "" {
+		return fmt.Errorf("createComment: %+v: %d: %w", r, number, ErrInvalidArguments)
+	}
+	var err error
+	switch r.Vendor {
+	case gitLab:
+		// long comments do not fit into the URL
+		_, err = s.gitLabPostForm(r, url.Values{
+			"body": {body},
+		}, "projects", gitLabProjectID(r), "issues", strconv.Itoa(number), "notes")
+	case gitHub:
+		_, err = s.gitHubRequest(r, "POST", map[string]any{
+			"body": body,
+		}, "repos", r.Owner, r.Repo, "issues", strconv.Itoa(number), "comments")
+	default:
+		err = ErrInvalidArguments
 	}
-	defer res.Body.Close()
-	data, err := io.ReadAll(res.Body)
 	if err != nil {
-		return "", wrapErr(err)
+		return fmt.Errorf("createComment: %s: %d: %w", r.Name(), number, err)
 	}
-	if res.StatusCode >= 400 {
-		return "", wrapErr(fmt.Errorf("%s: %w", res.Status, ErrHTTPError))
+	return nil
+}
+
+const (
+	issueStateOpen   = "open"
+	issueStateClosed = "closed"
+)
+
+// vendorIssue represents an issue as returned by a vendor.
+type vendorIssue struct {
+	assignees []string
+	comments  int
+	labels    []string
+	milestone string
+	number    int
+	state     string // either issueStateOpen or issueStateClosed
+	title     string
+	url       string
+}
+
+// getIssue fetches an issue from the vendor.
+func (s repoAPI) getIssue(r *Repo, number int) (*vendorIssue, error) {
+	if !r.isValid() || number == 0 {
+		return nil, fmt.Errorf("getIssue: %+v: %d: %w", r, number, ErrInvalidArguments)
 	}
-	var info map[string]any
-	if err := json.Unmarshal(data, &info); err != nil {
-		return "", wrapErr(err)
+	switch r.Vendor {
+	case gitLab:
+		return s.gitLabGetIssue(r, number)
+	case gitHub:
+		return s.gitHubGetIssue(r, number)
 	}
-	slog.Debug("Received response from gitlab for create issue", "data", info)
-	htmlURL, ok := info["web_url"].(string)
-	if !ok {
-		htmlURL = ""
+	return nil, ErrInvalidArguments
+}
+
+func (s repoAPI) gitLabGetIssue(r *Repo, number int) (*vendorIssue, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("gitLabGetIssue: %s: %d: %w", r.Name(), number, err)
+	}
+	data, err := s.gitLabRequest(r, "GET", nil, "projects", gitLabProjectID(r), "issues", strconv.Itoa(number))
+	if err != nil {
+		return nil, wrapErr(err)
 	}
-	return htmlURL, nil
+	info, ok := data.(map[string]any)
+	if !ok {
+		return nil, wrapErr(fmt.Errorf("unexpected response: %v", data))
+	}
+	x := &vendorIssue{number: number}
+	x.title, _ = info["title"].(string)
+	x.url, _ = info["web_url"].(string)
+	if state, _ := info["state"].(string); state == "closed" {
+		x.state = issueStateClosed
+	} else {
+		x.state = issueStateOpen
+	}
+	if labels, ok := info["labels"].([]any); ok {
+		for _, l := range labels {
+			if name, ok := l.(string); ok {
+				x.labels = append(x.labels, name)
+			}
+		}
+	}
+	if assignees, ok := info["assignees"].([]any); ok {
+		for _, a := range assignees {
+			if m, ok := a.(map[string]any); ok {
+				if name, ok := m["username"].(string); ok {
+					x.assignees = append(x.assignees, name)
+				}
+			}
+		}
+	}
+	if m, ok := info["milestone"].(map[string]any); ok {
+		x.milestone, _ = m["title"].(string)
+	}
+	if n, ok := info["user_notes_count"].(float64); ok {
+		x.comments = int(n)
+	}
+	return x, nil
 }
 
-func (s repoAPI) gitHubCreateIssue(r *Repo, arg createIssueParams) (string, error) {
+func (s repoAPI) gitHubGetIssue(r *Repo, number int) (*vendorIssue, error) {
 	wrapErr := func(err error) error {
-		return fmt.Errorf("gitHubCreateIssue: %+v: %w", arg, err)
+		return fmt.Errorf("gitHubGetIssue: %s: %d: %w", r.Name(), number, err)
 	}
-	u, err := url.JoinPath(gitHubBaseURL, "repos", r.Owner, r.Repo, "issues")
+	data, err := s.gitHubRequest(r, "GET", nil, "repos", r.Owner, r.Repo, "issues", strconv.Itoa(number))
 	if err != nil {
-		return "", wrapErr(err)
+		return nil, wrapErr(err)
 	}
-	params := map[string]any{
-		"title": arg.title,
-		"body":  arg.body,
+	info, ok := data.(map[string]any)
+	if !ok {
+		return nil, wrapErr(fmt.Errorf("unexpected response: %v", data))
+	}
+	x := &vendorIssue{number: number}
+	x.title, _ = info["title"].(string)
+	x.url, _ = info["html_url"].(string)
+	if state, _ := info["state"].(string); state == "closed" {
+		x.state = issueStateClosed
+	} else {
+		x.state = issueStateOpen
+	}
+	if labels, ok := info["labels"].([]any); ok {
+		for _, l := range labels {
+			if m, ok := l.(map[string]any); ok {
+				if name, ok := m["name"].(string); ok {
+					x.labels = append(x.labels, name)
+				}
+			}
+		}
+	}
+	if assignees, ok := info["assignees"].([]any); ok {
+		for _, a := range assignees {
+			if m, ok := a.(map[string]any); ok {
+				if name, ok := m["login"].(string); ok {
+					x.assignees = append(x.assignees, name)
+				}
+			}
+		}
+	}
+	if m, ok := info["milestone"].(map[string]any); ok {
+		x.milestone, _ = m["title"].(string)
+	}
+	if n, ok := info["comments"].(float64); ok {
+		x.comments = int(n)
+	}
+	return x, nil
+}
+
+// addLabels adds labels to an existing issue.
+func (s repoAPI) addLabels(r *Repo, number int, labels []string) error {
+	if !r.isValid() || number == 0 || len(labels) == 0 {
+		return fmt.Errorf("addLabels: %+v: %d: %w", r, number, ErrInvalidArguments)
 	}
-	if len(arg.labels) > 0 {
-		params["labels"] = arg.labels
+	var err error
+	switch r.Vendor {
+	case gitLab:
+		_, err = s.gitLabRequest(r, "PUT", url.Values{
+			"add_labels": {strings.Join(labels, ",")},
+		}, "projects", gitLabProjectID(r), "issues", strconv.Itoa(number))
+	case gitHub:
+		_, err = s.gitHubRequest(r, "POST", map[string]any{
+			"labels": labels,
+		}, "repos", r.Owner, r.Repo, "issues", strconv.Itoa(number), "labels")
+	default:
+		err = ErrInvalidArguments
+	}
+	if err != nil {
+		return fmt.Errorf("addLabels: %s: %d: %w", r.Name(), number, err)
+	}
+	return nil
+}
+
+// assignToTokenOwner assigns an issue to the user who owns the token of a repo.
+func (s repoAPI) assignToTokenOwner(r *Repo, number int) error {
+	if !r.isValid() || number == 0 {
+		return fmt.Errorf("assignToTokenOwner: %+v: %d: %w", r, number, ErrInvalidArguments)
+	}
+	err := func() error {
+		switch r.Vendor {
+		case gitLab:
+			data, err := s.gitLabRequest(r, "GET", nil, "user")
+			if err != nil {
+				return err
+			}
+			m, _ := data.(map[string]any)
+			id, ok := m["id"].(float64)
+			if !ok {
+				return fmt.Errorf("user ID missing in response")
+			}
+			_, err = s.gitLabRequest(r, "PUT", url.Values{
+				"assignee_ids[]": {strconv.Itoa(int(id))},
+			}, "projects", gitLabProjectID(r), "issues", strconv.Itoa(number))
+			return err
+		case gitHub:
+			data, err := s.gitHubRequest(r, "GET", nil, "user")
+			if err != nil {
+				return err
+			}
+			m, _ := data.(map[string]any)
+			login, ok := m["login"].(string)
+			if !ok {
+				return fmt.Errorf("login missing in response")
+			}
+			_, err = s.gitHubRequest(r, "POST", map[string]any{
+				"assignees": []string{login},
+			}, "repos", r.Owner, r.Repo, "issues", strconv.Itoa(number), "assignees")
+			return err
+		}
+		return ErrInvalidArguments
+	}()
+	if err != nil {
+		return fmt.Errorf("assignToTokenOwner: %s: %d: %w", r.Name(), number, err)
+	}
+	return nil
+}
+
+// closeIssue closes an existing issue as not planned.
+func (s repoAPI) closeIssue(r *Repo, number int) error {
+	if !r.isValid() || number == 0 {
+		return fmt.Errorf("closeIssue: %+v: %d: %w", r, number, ErrInvalidArguments)
+	}
+	var err error
+	switch r.Vendor {
+	case gitLab:
+		_, err = s.gitLabRequest(r, "PUT", url.Values{
+			"state_event": {"close"},
+		}, "projects", gitLabProjectID(r), "issues", strconv.Itoa(number))
+	case gitHub:
+		_, err = s.gitHubRequest(r, "PATCH", map[string]any{
+			"state":        "closed",
+			"state_reason": "not_planned",
+		}, "repos", r.Owner, r.Repo, "issues", strconv.Itoa(number))
+	default:
+		err = ErrInvalidArguments
 	}
-	body, err := json.Marshal(params)
 	if err != nil {
-		return "", wrapErr(err)
+		return fmt.Errorf("closeIssue: %s: %d: %w", r.Name(), number, err)
+	}
+	return nil
+}
+
+// vendorComment represents a comment on an issue as returned by a vendor.
+type vendorComment struct {
+	author string
+	body   string
+	id     int
+}
+
+// listComments fetches the latest comments of an issue ordered by creation.
+// Comments before since are skipped when the vendor supports it.
+func (s repoAPI) listComments(r *Repo, number int, since time.Time) ([]vendorComment, error) {
+	if !r.isValid() || number == 0 {
+		return nil, fmt.Errorf("listComments: %+v: %d: %w", r, number, ErrInvalidArguments)
+	}
+	var comments []vendorComment
+	var err error
+	switch r.Vendor {
+	case gitLab:
+		comments, err = s.gitLabListComments(r, number)
+	case gitHub:
+		comments, err = s.gitHubListComments(r, number, since)
+	default:
+		err = ErrInvalidArguments
 	}
-	req, err := http.NewRequest("POST", u, bytes.NewBuffer(body))
 	if err != nil {
-		return "", wrapErr(err)
+		return nil, fmt.Errorf("listComments: %s: %d: %w", r.Name(), number, err)
+	}
+	return comments, nil
+}
+
+func (s repoAPI) gitLabListComments(r *Repo, number int) ([]vendorComment, error) {
+	data, err := s.gitLabRequest(r, "GET", url.Values{
+		"order_by": {"created_at"},
+		"per_page": {"100"},
+		"sort":     {"desc"},
+	}, "projects", gitLabProjectID(r), "issues", strconv.Itoa(number), "notes")
+	if err != nil {
+		return nil, err
+	}
+	notes, _ := data.([]any)
+	var comments []vendorComment
+	for _, n := range notes {
+		m, ok := n.(map[string]any)
+		if !ok {
+			continue
+		}
+		if system, _ := m["system"].(bool); system {
+			continue
+		}
+		var c vendorComment
+		if id, ok := m["id"].(float64); ok {
+			c.id = int(id)
+		}
+		c.body, _ = m["body"].(string)
+		if author, ok := m["author"].(map[string]any); ok {
+			c.author, _ = author["username"].(string)
+		}
+		comments = append(comments, c)
+	}
+	slices.Reverse(comments)
+	return comments, nil
+}
+
+func (s repoAPI) gitHubListComments(r *Repo, number int, since time.Time) ([]vendorComment, error) {
+	const perPage = 100
+	var comments []vendorComment
+	for page := 1; ; page++ {
+		params := map[string]any{"page": page, "per_page": perPage}
+		if !since.IsZero() {
+			params["since"] = since.UTC().Format(time.RFC3339)
+		}
+		data, err := s.gitHubRequest(r, "GET", params, "repos", r.Owner, r.Repo, "issues", strconv.Itoa(number), "comments")
+		if err != nil {
+			return nil, err
+		}
+		items, _ := data.([]any)
+		for _, item := range items {
+			m, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			var c vendorComment
+			if id, ok := m["id"].(float64); ok {
+				c.id = int(id)
+			}
+			c.body, _ = m["body"].(string)
+			if user, ok := m["user"].(map[string]any); ok {
+				c.author, _ = user["login"].(string)
+			}
+			comments = append(comments, c)
+		}
+		if len(items) < perPage {
+			break
+		}
+	}
+	return comments, nil
+}
+
+// uploadText uploads a text as secret gist on GitHub or as private snippet of the repo on GitLab
+// and returns its URL.
+func (s repoAPI) uploadText(r *Repo, title, filename, content string) (string, error) {
+	if !r.isValid() || filename == "" || content == "" {
+		return "", fmt.Errorf("uploadText: %+v: %w", r, ErrInvalidArguments)
+	}
+	var data any
+	var key string
+	var err error
+	switch r.Vendor {
+	case gitLab:
+		key = "web_url"
+		data, err = s.gitLabPostForm(r, url.Values{
+			"title":              {title},
+			"visibility":         {"private"},
+			"files[][file_path]": {filename},
+			"files[][content]":   {content},
+		}, "projects", gitLabProjectID(r), "snippets")
+	case gitHub:
+		key = "html_url"
+		data, err = s.gitHubRequest(r, "POST", map[string]any{
+			"description": title,
+			"public":      false,
+			"files": map[string]any{
+				filename: map[string]any{"content": content},
+			},
+		}, "gists")
+	default:
+		err = ErrInvalidArguments
+	}
+	if err != nil {
+		return "", fmt.Errorf("uploadText: %s: %w", r.Name(), err)
+	}
+	m, _ := data.(map[string]any)
+	u, ok := m[key].(string)
+	if !ok {
+		return "", fmt.Errorf("uploadText: %s: URL missing in response", r.Name())
+	}
+	return u, nil
+}
+
+// gitHubRequest sends a request to the GitHub API with the token of a repo and returns the decoded response.
+// The path elements are relative to the base URL. Params are sent as JSON body when not nil,
+// or as query parameters for GET requests.
+func (s repoAPI) gitHubRequest(r *Repo, method string, params map[string]any, elem ...string) (any, error) {
+	u, err := url.JoinPath(gitHubBaseURL, elem...)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if method == "GET" && params != nil {
+		v := url.Values{}
+		for k, x := range params {
+			v.Set(k, fmt.Sprint(x))
+		}
+		u += "?" + v.Encode()
+	} else if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
 	}
 	req.Header.Add("Accept", "application/vnd.github+json")
 	req.Header.Add("Authorization", "Bearer "+r.Token)
 	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
-	res, err := http.DefaultClient.Do(req)
+	res, err := s.HTTPClient.Do(req)
 	if err != nil {
-		return "", wrapErr(err)
+		return nil, err
 	}
 	defer res.Body.Close()
 	data, err := io.ReadAll(res.Body)
 	if err != nil {
-		return "", wrapErr(err)
+		return nil, err
 	}
 	if res.StatusCode >= 400 {
-		return "", wrapErr(fmt.Errorf("%s: %w", res.Status, ErrHTTPError))
+		return nil, fmt.Errorf("%s: %w", res.Status, ErrHTTPError)
 	}
-	var info map[string]any
-	if err := json.Unmarshal(data, &info); err != nil {
-		return "", wrapErr(err)
+	var info any
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, err
+		}
 	}
-	slog.Debug("Received response from github for create issue", "data", info)
-	htmlURL, ok := info["html_url"].(string)
-	if !ok {
-		htmlURL = ""
+	slog.Debug("Received response from github", "method", method, "url", u, "data", info)
+	return info, nil
+}
+
+// gitLabRequest sends a request to the GitLab API with the token of a repo and returns the decoded response.
+// The path elements are relative to the base URL. Values are sent as query parameters.
+func (s repoAPI) gitLabRequest(r *Repo, method string, v url.Values, elem ...string) (any, error) {
+	u, err := url.JoinPath(gitLabBaseURL, elem...)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		v = url.Values{}
+	}
+	v.Set("private_token", r.Token)
+	req, err := http.NewRequest(method, u+"?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s: %w", res.Status, ErrHTTPError)
+	}
+	var info any
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, err
+		}
 	}
-	return htmlURL, nil
+	slog.Debug("Received response from gitlab", "method", method, "url", u, "data", info)
+	return info, nil
+}
+
+// gitLabPostForm sends a POST request to the GitLab API with the token of a repo and returns the decoded response.
+// Unlike [repoAPI.gitLabRequest] the values are sent as form in the body, which allows sending long texts.
+func (s repoAPI) gitLabPostForm(r *Repo, v url.Values, elem ...string) (any, error) {
+	u, err := url.JoinPath(gitLabBaseURL, elem...)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{"private_token": {r.Token}}
+	res, err := s.HTTPClient.Post(u+"?"+q.Encode(), "application/x-www-form-urlencoded", strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s: %w", res.Status, ErrHTTPError)
+	}
+	var info any
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, err
+		}
+	}
+	slog.Debug("Received response from gitlab", "method", "POST", "url", u, "data", info)
+	return info, nil
+}
+
+// gitLabProjectID returns the ID of a repo for the GitLab API.
+func gitLabProjectID(r *Repo) string {
+	return url.PathEscape(r.Owner + "/" + r.Repo)
 }
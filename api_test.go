@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
@@ -56,10 +59,11 @@ func TestGitHub(t *testing.T) {
 				return httpmock.NewJsonResponse(200, map[string]any{
 					"id":       "123",
 					"html_url": "url",
+					"number":   7,
 				})
 			})
 		a := newRepoAPI()
-		got, err := a.createIssue(&Repo{
+		number, got, err := a.createIssue(&Repo{
 			Owner:  "owner",
 			Repo:   "repo",
 			Token:  "token",
@@ -71,6 +75,256 @@ func TestGitHub(t *testing.T) {
 		})
 		if assert.NoError(t, err) {
 			assert.Equal(t, "url", got)
+			assert.Equal(t, 7, number)
+		}
+	})
+
+	t.Run("can create comment", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"POST",
+			"https://api.github.com/repos/owner/repo/issues/7/comments",
+			func(req *http.Request) (*http.Response, error) {
+				if req.Header.Get("Authorization") != "Bearer token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				return httpmock.NewJsonResponse(201, map[string]any{
+					"id": 123,
+				})
+			})
+		a := newRepoAPI()
+		err := a.createComment(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitHub,
+			UserID: "user",
+		}, 7, "body")
+		assert.NoError(t, err)
+	})
+
+	t.Run("can get issue", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"GET",
+			"https://api.github.com/repos/owner/repo/issues/7",
+			func(req *http.Request) (*http.Response, error) {
+				if req.Header.Get("Authorization") != "Bearer token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				return httpmock.NewJsonResponse(200, map[string]any{
+					"number":    7,
+					"title":     "title",
+					"state":     "closed",
+					"html_url":  "url",
+					"labels":    []map[string]any{{"name": "bug"}},
+					"assignees": []map[string]any{{"login": "alice"}},
+					"milestone": map[string]any{"title": "v1"},
+					"comments":  3,
+				})
+			})
+		a := newRepoAPI()
+		got, err := a.getIssue(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitHub,
+			UserID: "user",
+		}, 7)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 7, got.number)
+			assert.Equal(t, "title", got.title)
+			assert.Equal(t, issueStateClosed, got.state)
+			assert.Equal(t, "url", got.url)
+			assert.Equal(t, []string{"bug"}, got.labels)
+			assert.Equal(t, []string{"alice"}, got.assignees)
+			assert.Equal(t, "v1", got.milestone)
+			assert.Equal(t, 3, got.comments)
+		}
+	})
+
+	t.Run("can add labels", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"POST",
+			"https://api.github.com/repos/owner/repo/issues/7/labels",
+			func(req *http.Request) (*http.Response, error) {
+				if req.Header.Get("Authorization") != "Bearer token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				var data map[string][]string
+				if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(200, []map[string]any{{"name": data["labels"][0]}})
+			})
+		a := newRepoAPI()
+		err := a.addLabels(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitHub,
+			UserID: "user",
+		}, 7, []string{"bug"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, httpmock.GetTotalCallCount())
+		}
+	})
+
+	t.Run("can assign issue to token owner", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"GET",
+			"https://api.github.com/user",
+			httpmock.NewJsonResponderOrPanic(200, map[string]any{"login": "alice"}),
+		)
+		httpmock.RegisterResponder(
+			"POST",
+			"https://api.github.com/repos/owner/repo/issues/7/assignees",
+			func(req *http.Request) (*http.Response, error) {
+				var data map[string][]string
+				if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				if data["assignees"][0] != "alice" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(201, map[string]any{"number": 7})
+			})
+		a := newRepoAPI()
+		err := a.assignToTokenOwner(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitHub,
+			UserID: "user",
+		}, 7)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 2, httpmock.GetTotalCallCount())
+		}
+	})
+
+	t.Run("can close issue", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"PATCH",
+			"https://api.github.com/repos/owner/repo/issues/7",
+			func(req *http.Request) (*http.Response, error) {
+				var data map[string]string
+				if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				if data["state"] != "closed" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(200, map[string]any{"number": 7})
+			})
+		a := newRepoAPI()
+		err := a.closeIssue(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitHub,
+			UserID: "user",
+		}, 7)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, httpmock.GetTotalCallCount())
+		}
+	})
+
+	t.Run("can list comments", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"GET",
+			"https://api.github.com/repos/owner/repo/issues/7/comments",
+			func(req *http.Request) (*http.Response, error) {
+				if req.Header.Get("Authorization") != "Bearer token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				if req.URL.Query().Get("since") != "2025-01-02T03:04:05Z" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(200, []map[string]any{
+					{"id": 1, "body": "first", "user": map[string]any{"login": "alice"}},
+					{"id": 2, "body": "second", "user": map[string]any{"login": "bob"}},
+				})
+			})
+		a := newRepoAPI()
+		got, err := a.listComments(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitHub,
+			UserID: "user",
+		}, 7, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []vendorComment{
+				{author: "alice", body: "first", id: 1},
+				{author: "bob", body: "second", id: 2},
+			}, got)
+		}
+	})
+	t.Run("can list comments across pages", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"GET",
+			"https://api.github.com/repos/owner/repo/issues/7/comments",
+			func(req *http.Request) (*http.Response, error) {
+				page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+				n := 100
+				if page == 2 {
+					n = 1
+				}
+				items := make([]map[string]any, n)
+				for i := range items {
+					items[i] = map[string]any{"id": (page-1)*100 + i + 1, "body": "x", "user": map[string]any{"login": "alice"}}
+				}
+				return httpmock.NewJsonResponse(200, items)
+			})
+		a := newRepoAPI()
+		got, err := a.listComments(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitHub,
+			UserID: "user",
+		}, 7, time.Time{})
+		if assert.NoError(t, err) {
+			assert.Len(t, got, 101)
+			assert.Equal(t, 101, got[100].id)
+		}
+	})
+	t.Run("can upload text as gist", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"POST",
+			"https://api.github.com/gists",
+			func(req *http.Request) (*http.Response, error) {
+				if req.Header.Get("Authorization") != "Bearer token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				var data struct {
+					Public bool
+					Files  map[string]struct{ Content string }
+				}
+				if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				if data.Public || data.Files["issue.md"].Content != "content" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(201, map[string]any{"html_url": "https://gist.github.com/1"})
+			})
+		a := newRepoAPI()
+		got, err := a.uploadText(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitHub,
+			UserID: "user",
+		}, "title", "issue.md", "content")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "https://gist.github.com/1", got)
 		}
 	})
 }
@@ -117,13 +371,17 @@ func TestGitLab(t *testing.T) {
 				if v.Get("private_token") != "token" {
 					return httpmock.NewStringResponse(401, ""), nil
 				}
+				if err := req.ParseForm(); err != nil || req.PostForm.Get("description") != "body" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
 				return httpmock.NewJsonResponse(200, map[string]any{
 					"id":      "123",
+					"iid":     7,
 					"web_url": "url",
 				})
 			})
 		a := newRepoAPI()
-		got, err := a.createIssue(&Repo{
+		number, got, err := a.createIssue(&Repo{
 			Owner:  "owner",
 			Repo:   "repo",
 			Token:  "token",
@@ -135,6 +393,221 @@ func TestGitLab(t *testing.T) {
 		})
 		if assert.NoError(t, err) {
 			assert.Equal(t, "url", got)
+			assert.Equal(t, 7, number)
+		}
+	})
+
+	t.Run("can create comment", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"POST",
+			"https://gitlab.com/api/v4/projects/owner%2Frepo/issues/7/notes",
+			func(req *http.Request) (*http.Response, error) {
+				v := req.URL.Query()
+				if v.Get("private_token") != "token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				if err := req.ParseForm(); err != nil || req.PostForm.Get("body") != "body" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(201, map[string]any{
+					"id": 123,
+				})
+			})
+		a := newRepoAPI()
+		err := a.createComment(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitLab,
+			UserID: "user",
+		}, 7, "body")
+		assert.NoError(t, err)
+	})
+
+	t.Run("can get issue", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"GET",
+			"https://gitlab.com/api/v4/projects/owner%2Frepo/issues/7",
+			func(req *http.Request) (*http.Response, error) {
+				v := req.URL.Query()
+				if v.Get("private_token") != "token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				return httpmock.NewJsonResponse(200, map[string]any{
+					"iid":              7,
+					"title":            "title",
+					"state":            "opened",
+					"web_url":          "url",
+					"labels":           []string{"bug"},
+					"assignees":        []map[string]any{{"username": "alice"}},
+					"milestone":        map[string]any{"title": "v1"},
+					"user_notes_count": 3,
+				})
+			})
+		a := newRepoAPI()
+		got, err := a.getIssue(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitLab,
+			UserID: "user",
+		}, 7)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 7, got.number)
+			assert.Equal(t, "title", got.title)
+			assert.Equal(t, issueStateOpen, got.state)
+			assert.Equal(t, "url", got.url)
+			assert.Equal(t, []string{"bug"}, got.labels)
+			assert.Equal(t, []string{"alice"}, got.assignees)
+			assert.Equal(t, "v1", got.milestone)
+			assert.Equal(t, 3, got.comments)
+		}
+	})
+
+	t.Run("can add labels", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"PUT",
+			"https://gitlab.com/api/v4/projects/owner%2Frepo/issues/7",
+			func(req *http.Request) (*http.Response, error) {
+				v := req.URL.Query()
+				if v.Get("private_token") != "token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				if v.Get("add_labels") != "bug,ui" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(200, map[string]any{"iid": 7})
+			})
+		a := newRepoAPI()
+		err := a.addLabels(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitLab,
+			UserID: "user",
+		}, 7, []string{"bug", "ui"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, httpmock.GetTotalCallCount())
+		}
+	})
+
+	t.Run("can assign issue to token owner", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"GET",
+			"https://gitlab.com/api/v4/user",
+			httpmock.NewJsonResponderOrPanic(200, map[string]any{"id": 42}),
+		)
+		httpmock.RegisterResponder(
+			"PUT",
+			"https://gitlab.com/api/v4/projects/owner%2Frepo/issues/7",
+			func(req *http.Request) (*http.Response, error) {
+				v := req.URL.Query()
+				if v.Get("assignee_ids[]") != "42" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(200, map[string]any{"iid": 7})
+			})
+		a := newRepoAPI()
+		err := a.assignToTokenOwner(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitLab,
+			UserID: "user",
+		}, 7)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 2, httpmock.GetTotalCallCount())
+		}
+	})
+
+	t.Run("can close issue", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"PUT",
+			"https://gitlab.com/api/v4/projects/owner%2Frepo/issues/7",
+			func(req *http.Request) (*http.Response, error) {
+				v := req.URL.Query()
+				if v.Get("state_event") != "close" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(200, map[string]any{"iid": 7})
+			})
+		a := newRepoAPI()
+		err := a.closeIssue(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitLab,
+			UserID: "user",
+		}, 7)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, httpmock.GetTotalCallCount())
+		}
+	})
+
+	t.Run("can list comments", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"GET",
+			"https://gitlab.com/api/v4/projects/owner%2Frepo/issues/7/notes",
+			func(req *http.Request) (*http.Response, error) {
+				v := req.URL.Query()
+				if v.Get("private_token") != "token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				return httpmock.NewJsonResponse(200, []map[string]any{
+					{"id": 3, "body": "second", "author": map[string]any{"username": "bob"}},
+					{"id": 2, "body": "added label", "system": true, "author": map[string]any{"username": "bob"}},
+					{"id": 1, "body": "first", "author": map[string]any{"username": "alice"}},
+				})
+			})
+		a := newRepoAPI()
+		got, err := a.listComments(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitLab,
+			UserID: "user",
+		}, 7, time.Time{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []vendorComment{
+				{author: "alice", body: "first", id: 1},
+				{author: "bob", body: "second", id: 3},
+			}, got)
+		}
+	})
+
+	t.Run("can upload text as snippet", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"POST",
+			"https://gitlab.com/api/v4/projects/owner%2Frepo/snippets",
+			func(req *http.Request) (*http.Response, error) {
+				if req.URL.Query().Get("private_token") != "token" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				if err := req.ParseForm(); err != nil {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				if req.PostForm.Get("visibility") != "private" || req.PostForm.Get("files[][content]") != "content" {
+					return httpmock.NewStringResponse(400, ""), nil
+				}
+				return httpmock.NewJsonResponse(201, map[string]any{"web_url": "https://gitlab.com/snippets/1"})
+			})
+		a := newRepoAPI()
+		got, err := a.uploadText(&Repo{
+			Owner:  "owner",
+			Repo:   "repo",
+			Token:  "token",
+			Vendor: gitLab,
+			UserID: "user",
+		}, "title", "issue.md", "content")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "https://gitlab.com/snippets/1", got)
 		}
 	})
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// addToBasket adds a message to the basket of the user
+// and responds with the actions for the basket.
+func (b *Bot) addToBasket(ic *discordgo.InteractionCreate, userID string, m *discordgo.Message) error {
+	if m == nil || m.Author == nil {
+		return fmt.Errorf("message not found for basket")
+	}
+	u, err := b.st.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if !u.IsBasketForGuild(ic.GuildID) {
+		return b.respondEphemeral(ic, ":x: Your basket has messages from another server. Please create the issue there or clear the basket first.")
+	}
+	if len(u.Basket) >= maxBasketMessages {
+		return b.respondEphemeral(ic, fmt.Sprintf(":x: Your basket is full with %d messages", len(u.Basket)))
+	}
+	added := u.AddToBasket(BasketMessage{
+		Attachments: makeAttachments(m),
+		AuthorID:    m.Author.ID,
+		AuthorName:  m.Author.Username,
+		ChannelID:   ic.ChannelID,
+		Content:     b.makeMessageContent(ic.GuildID, m, ic.ApplicationCommandData().Resolved),
+		GuildID:     ic.GuildID,
+		MessageID:   m.ID,
+		Timestamp:   m.Timestamp,
+	})
+	if err := b.st.UpdateUser(u); err != nil {
+		return err
+	}
+	var content string
+	if added {
+		content = fmt.Sprintf(":shopping_cart: Message added to your issue draft. It now has %d message(s).", len(u.Basket))
+	} else {
+		content = fmt.Sprintf(":shopping_cart: Message is already in your issue draft. It has %d message(s).", len(u.Basket))
+	}
+	err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							CustomID: idBasketCreate,
+							Label:    "Create issue from basket",
+						},
+						discordgo.Button{
+							CustomID: idBasketClear,
+							Label:    "Clear basket",
+							Style:    discordgo.DangerButton,
+						},
+					},
+				},
+			},
+		},
+	})
+	return err
+}
+
+// createIssueFromBasket starts creating an issue from the messages in the basket of the user.
+func (b *Bot) createIssueFromBasket(ic *discordgo.InteractionCreate, userID string) error {
+	u, err := b.st.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if len(u.Basket) == 0 {
+		err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    ":x: Your basket is empty",
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		return err
+	}
+	if !u.IsBasketForGuild(ic.GuildID) {
+		return b.respondEphemeral(ic, ":x: Your basket has messages from another server. Please create the issue there.")
+	}
+	first := u.Basket[0]
+	s := createIssueData{
+		authorID:         first.AuthorID,
+		authorName:       first.AuthorName,
+		basket:           u.Basket,
+		channelID:        first.ChannelID,
+		guildID:          first.GuildID,
+		messageContent:   first.Content,
+		messageID:        first.MessageID,
+		messageTimestamp: first.Timestamp,
+		userID:           userID,
+	}
+	sessionID := b.newSessionID()
+	if err := b.sessions.Store(sessionID, s); err != nil {
+		return err
+	}
+	d, err := b.makeCreateIssueStartData(ic, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: d,
+	})
+	return err
+}
+
+// clearBasket removes all messages from the basket of the user.
+func (b *Bot) clearBasket(userID string) error {
+	u, err := b.st.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	u.Basket = nil
+	return b.st.UpdateUser(u)
+}
+
+// removeFromBasket removes messages from the basket of the user, e.g. after an issue was created from them.
+func (b *Bot) removeFromBasket(userID string, messages []BasketMessage) error {
+	u, err := b.st.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	u.RemoveFromBasket(messages)
+	return b.st.UpdateUser(u)
+}
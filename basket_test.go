@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeBasketIssueParams(t *testing.T) {
+	s := createIssueData{
+		basket: []BasketMessage{
+			{AuthorName: "alice", ChannelID: "c1", Content: "first\nline", GuildID: "g1", MessageID: "m1"},
+			{AuthorName: "bob", ChannelID: "c2", Content: "second", MessageID: "m2"},
+		},
+		issueType: bugReport,
+		title:     "title",
+	}
+	got, err := makeCreateIssueParams(defaultIssueTemplates, s, "description")
+	require.NoError(t, err)
+	assert.Equal(t, "title", got.title)
+	assert.Equal(t, []string{"bug"}, got.labels)
+	assert.Equal(
+		t,
+		"> first\n> line\n\n*Posted by **alice** on [Discord](https://discord.com/channels/g1/c1/m1)*\n\n"+
+			"> second\n\n*Posted by **bob** on [Discord](https://discord.com/channels/@me/c2/m2)*\n\n"+
+			"description",
+		got.body,
+	)
+}
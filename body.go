@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	maxCodeBlockLength    = 2000       // code blocks with more characters are folded
+	maxCodeBlockLines     = 20         // code blocks with more lines are folded
+	maxTextAttachmentSize = 512 * 1024 // text attachments which are larger are only linked
+)
+
+var fencedCodeRx = regexp.MustCompile("(?s)```.*?```")
+
+// makeMessageContent returns the content of a Discord message as markdown for issues.
+// Attachments are not included, since they are added with [Bot.withAttachments] when the issue is created.
+func (b *Bot) makeMessageContent(guildID string, m *discordgo.Message, resolved *discordgo.ApplicationCommandInteractionDataResolved) string {
+	return convertMessage(m, b.makeMentionNames(guildID, m, resolved))
+}
+
+// makeAttachments returns references to the attachments of a Discord message.
+func makeAttachments(m *discordgo.Message) []Attachment {
+	var attachments []Attachment
+	for _, a := range m.Attachments {
+		attachments = append(attachments, newAttachment(a))
+	}
+	return attachments
+}
+
+func newAttachment(a *discordgo.MessageAttachment) Attachment {
+	return Attachment{
+		ContentType: a.ContentType,
+		Filename:    a.Filename,
+		Size:        a.Size,
+		URL:         a.URL,
+	}
+}
+
+// withAttachments returns the data of a session with the attachments added to the content of its messages.
+// Text attachments like logs are fetched and included as collapsible sections when fetch is true,
+// which can take a while. All other attachments are linked.
+func (b *Bot) withAttachments(s createIssueData, fetch bool) createIssueData {
+	s.messageContent = b.appendAttachments(s.messageContent, s.attachments, fetch)
+	s.attachments = nil
+	basket := slices.Clone(s.basket)
+	for i, m := range basket {
+		basket[i].Content = b.appendAttachments(m.Content, m.Attachments, fetch)
+		basket[i].Attachments = nil
+	}
+	s.basket = basket
+	return s
+}
+
+func (b *Bot) appendAttachments(content string, attachments []Attachment, fetch bool) string {
+	var parts []string
+	if content != "" {
+		parts = append(parts, content)
+	}
+	for _, a := range attachments {
+		if fetch {
+			parts = append(parts, b.convertAttachment(a))
+		} else {
+			parts = append(parts, linkAttachment(a))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// convertAttachment returns an attachment as markdown.
+// The content of text attachments is included, when it can be fetched.
+func (b *Bot) convertAttachment(a Attachment) string {
+	if isTextAttachment(a) && a.Size <= maxTextAttachmentSize {
+		text, err := b.fetchAttachment(a.URL)
+		if err == nil {
+			return foldText(a.Filename, codeBlock("", text))
+		}
+		slog.Warn("Failed to fetch attachment", "url", a.URL, "error", err)
+	}
+	return linkAttachment(a)
+}
+
+// linkAttachment returns a markdown link to an attachment. Images are embedded.
+// Links to attachments on Discord expire after some time, which is noted.
+func linkAttachment(a Attachment) string {
+	link := fmt.Sprintf("[%s](%s)", a.Filename, a.URL)
+	if strings.HasPrefix(a.ContentType, "image/") {
+		link = "!" + link
+	}
+	return link + " *(temporary link)*"
+}
+
+// fetchAttachment returns the content of a text attachment.
+func (b *Bot) fetchAttachment(url string) (string, error) {
+	res, err := b.api.HTTPClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return "", fmt.Errorf("%s: %w", res.Status, ErrHTTPError)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxTextAttachmentSize))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("not a text file")
+	}
+	return string(data), nil
+}
+
+// isTextAttachment reports whether an attachment is a text file, e.g. a log.
+func isTextAttachment(a Attachment) bool {
+	if strings.HasPrefix(a.ContentType, "text/") || strings.HasPrefix(a.ContentType, "application/json") {
+		return true
+	}
+	return a.ContentType == "" && strings.HasSuffix(strings.ToLower(a.Filename), ".log")
+}
+
+// foldCodeBlocks returns markdown with code blocks which are too long folded into collapsible sections.
+func foldCodeBlocks(s string) string {
+	return fencedCodeRx.ReplaceAllStringFunc(s, func(x string) string {
+		inner := x[3 : len(x)-3]
+		lang, code, found := strings.Cut(inner, "\n")
+		if !found {
+			lang, code = "", inner
+		}
+		code = strings.TrimSuffix(code, "\n")
+		lines := strings.Count(code, "\n") + 1
+		if lines <= maxCodeBlockLines && utf8.RuneCountInString(code) <= maxCodeBlockLength {
+			return x
+		}
+		summary := "Code"
+		if lines > 1 {
+			summary = fmt.Sprintf("Code (%d lines)", lines)
+		}
+		return "\n" + foldText(summary, codeBlock(strings.TrimSpace(lang), code)) + "\n"
+	})
+}
+
+// foldText returns markdown in a collapsible section.
+func foldText(summary, s string) string {
+	return fmt.Sprintf("<details><summary>%s</summary>\n\n%s\n\n</details>", summary, s)
+}
+
+// codeBlock returns text as fenced code block.
+// The fence is longer than any sequence of backticks in the text.
+func codeBlock(lang, text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimSuffix(text, "\n") + "\n" + fence
+}
+
+// fitIssueBody returns the body of an issue within the length limit of the vendor.
+// Bodies which are too long are truncated.
+// When enabled for the repo the full body is uploaded first and linked from the truncated body.
+func (b *Bot) fitIssueBody(r *Repo, title, body string) string {
+	maxLength := r.Vendor.MaxBodyLength()
+	if utf8.RuneCountInString(body) <= maxLength {
+		return body
+	}
+	note := fmt.Sprintf("*The text was truncated, because it exceeds the limit of %d characters.*", maxLength)
+	if r.UploadLongBodies {
+		u, err := b.api.uploadText(r, title, "issue.md", body)
+		if err != nil {
+			slog.Warn("Failed to upload issue body", "repo", r.Name(), "error", err)
+		} else {
+			note = fmt.Sprintf("*The text was truncated. The full text is available [here](%s).*", u)
+		}
+	}
+	return truncateMarkdown(body, maxLength-utf8.RuneCountInString(note)-2) + "\n\n" + note
+}
+
+// truncateMarkdown returns markdown truncated at a line break to at most maxLength characters.
+// Code blocks and collapsible sections which are cut off are closed.
+func truncateMarkdown(s string, maxLength int) string {
+	const reserved = 50 // for closing code blocks and sections
+	if utf8.RuneCountInString(s) <= maxLength {
+		return s
+	}
+	runes := []rune(s)
+	cut := string(runes[:max(maxLength-reserved, 0)])
+	if i := strings.LastIndex(cut, "\n"); i > 0 {
+		cut = cut[:i]
+	}
+	quotePrefix := func(l string) string {
+		p := strings.TrimRight(l[:len(l)-len(strings.TrimLeft(l, "> "))], " ")
+		if p == "" {
+			return ""
+		}
+		return p + " "
+	}
+	var fence, fencePrefix, lastPrefix string
+	var details []string // prefixes of open sections
+	for l := range strings.Lines(cut) {
+		prefix := quotePrefix(l)
+		text := strings.TrimSpace(strings.TrimLeft(l, "> "))
+		lastPrefix = prefix
+		if fence != "" {
+			if strings.HasPrefix(text, fence) && strings.Trim(text, "`") == "" {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(text, "```") {
+			fence = text[:len(text)-len(strings.TrimLeft(text, "`"))]
+			fencePrefix = prefix
+			continue
+		}
+		for range strings.Count(text, "<details>") {
+			details = append(details, prefix)
+		}
+		for range min(strings.Count(text, "</details>"), len(details)) {
+			details = details[:len(details)-1]
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString(cut)
+	sb.WriteString("\n" + lastPrefix + "…")
+	if fence != "" {
+		sb.WriteString("\n" + fencePrefix + fence)
+	}
+	for i := len(details) - 1; i >= 0; i-- {
+		p := details[i]
+		sb.WriteString("\n" + strings.TrimSpace(p) + "\n" + p + "</details>")
+	}
+	return sb.String()
+}
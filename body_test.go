@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldCodeBlocks(t *testing.T) {
+	long := strings.Repeat("line\n", maxCodeBlockLines+1)
+	cases := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"short code block", "see\n```go\nfmt.Println()\n```", "see\n```go\nfmt.Println()\n```"},
+		{
+			"long code block",
+			"see\n```go\n" + long + "```\nend",
+			"see\n\n<details><summary>Code (21 lines)</summary>\n\n```go\n" + long + "```\n\n</details>\n\nend",
+		},
+		{
+			"long code block on one line",
+			"```" + strings.Repeat("x", maxCodeBlockLength+1) + "```",
+			"\n<details><summary>Code</summary>\n\n```\n" + strings.Repeat("x", maxCodeBlockLength+1) + "\n```\n\n</details>\n",
+		},
+		{"no code", "text", "text"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, foldCodeBlocks(tc.s))
+		})
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	assert.Equal(t, "```go\nx\n```", codeBlock("go", "x\n"))
+	assert.Equal(t, "````\na ``` b\n````", codeBlock("", "a ``` b"))
+}
+
+func TestIsTextAttachment(t *testing.T) {
+	cases := []struct {
+		a    Attachment
+		want bool
+	}{
+		{Attachment{Filename: "log.txt", ContentType: "text/plain; charset=utf-8"}, true},
+		{Attachment{Filename: "data.json", ContentType: "application/json; charset=utf-8"}, true},
+		{Attachment{Filename: "app.log"}, true},
+		{Attachment{Filename: "image.png", ContentType: "image/png"}, false},
+		{Attachment{Filename: "archive.zip", ContentType: "application/zip"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.a.Filename, func(t *testing.T) {
+			assert.Equal(t, tc.want, isTextAttachment(tc.a))
+		})
+	}
+}
+
+func TestWithAttachments(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "https://cdn.discordapp.com/log.txt", httpmock.NewStringResponder(200, "panic: boom\n"))
+	httpmock.RegisterResponder("GET", "https://cdn.discordapp.com/missing.txt", httpmock.NewStringResponder(404, ""))
+	b := &Bot{api: newRepoAPI()}
+	m := &discordgo.Message{
+		Content: "it crashed",
+		Attachments: []*discordgo.MessageAttachment{
+			{Filename: "log.txt", ContentType: "text/plain", Size: 12, URL: "https://cdn.discordapp.com/log.txt"},
+			{Filename: "missing.txt", ContentType: "text/plain", Size: 12, URL: "https://cdn.discordapp.com/missing.txt"},
+			{Filename: "big.txt", ContentType: "text/plain", Size: maxTextAttachmentSize + 1, URL: "https://cdn.discordapp.com/big.txt"},
+			{Filename: "screen.png", ContentType: "image/png", URL: "https://cdn.discordapp.com/screen.png"},
+		},
+	}
+	s := createIssueData{
+		attachments:    makeAttachments(m),
+		messageContent: b.makeMessageContent("", m, nil),
+	}
+	t.Run("should fetch text attachments", func(t *testing.T) {
+		got := b.withAttachments(s, true)
+		want := "it crashed\n\n" +
+			"<details><summary>log.txt</summary>\n\n```\npanic: boom\n```\n\n</details>\n\n" +
+			"[missing.txt](https://cdn.discordapp.com/missing.txt) *(temporary link)*\n\n" +
+			"[big.txt](https://cdn.discordapp.com/big.txt) *(temporary link)*\n\n" +
+			"![screen.png](https://cdn.discordapp.com/screen.png) *(temporary link)*"
+		assert.Equal(t, want, got.messageContent)
+		assert.Empty(t, got.attachments)
+	})
+	t.Run("should only link attachments when not fetching", func(t *testing.T) {
+		httpmock.ZeroCallCounters()
+		got := b.withAttachments(s, false)
+		assert.True(t, strings.HasPrefix(got.messageContent, "it crashed\n\n[log.txt](https://cdn.discordapp.com/log.txt)"), got.messageContent)
+		assert.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+	t.Run("should add attachments to basket messages", func(t *testing.T) {
+		s := createIssueData{
+			basket: []BasketMessage{{Content: "first", Attachments: makeAttachments(m)[3:]}},
+		}
+		got := b.withAttachments(s, true)
+		assert.Equal(t, "first\n\n![screen.png](https://cdn.discordapp.com/screen.png) *(temporary link)*", got.basket[0].Content)
+		assert.Equal(t, "first", s.basket[0].Content, "basket of the session must not change")
+	})
+}
+
+func TestTruncateMarkdown(t *testing.T) {
+	t.Run("should not change short markdown", func(t *testing.T) {
+		assert.Equal(t, "short", truncateMarkdown("short", 100))
+	})
+	t.Run("should truncate at line break", func(t *testing.T) {
+		s := strings.Repeat("a line of text\n", 20)
+		got := truncateMarkdown(s, 100)
+		assert.LessOrEqual(t, utf8.RuneCountInString(got), 100)
+		assert.True(t, strings.HasSuffix(got, "a line of text\n…"), got)
+	})
+	t.Run("should close code block", func(t *testing.T) {
+		s := "text\n```go\n" + strings.Repeat("code\n", 100) + "```"
+		got := truncateMarkdown(s, 150)
+		assert.LessOrEqual(t, utf8.RuneCountInString(got), 150)
+		assert.True(t, strings.HasSuffix(got, "code\n…\n```"), got)
+	})
+	t.Run("should close quoted code block and section", func(t *testing.T) {
+		s := quoteMarkdown(foldText("log.txt", codeBlock("", strings.Repeat("line\n", 100))))
+		got := truncateMarkdown(s, 200)
+		assert.LessOrEqual(t, utf8.RuneCountInString(got), 200)
+		assert.True(t, strings.HasSuffix(got, "> line\n> …\n> ```\n>\n> </details>"), got)
+	})
+	t.Run("should not close closed sections", func(t *testing.T) {
+		s := foldText("a", "b") + "\n" + strings.Repeat("text\n", 100)
+		got := truncateMarkdown(s, 100)
+		assert.Equal(t, 1, strings.Count(got, "</details>"))
+	})
+}
+
+func TestFitIssueBody(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	long := strings.Repeat("a line of text\n", gitHub.MaxBodyLength()/10)
+	b := &Bot{api: newRepoAPI()}
+	t.Run("should not change short body", func(t *testing.T) {
+		r := &Repo{Owner: "owner", Repo: "repo", Token: "token", UserID: "user", Vendor: gitHub}
+		assert.Equal(t, "body", b.fitIssueBody(r, "title", "body"))
+	})
+	t.Run("should truncate long body", func(t *testing.T) {
+		r := &Repo{Owner: "owner", Repo: "repo", Token: "token", UserID: "user", Vendor: gitHub}
+		got := b.fitIssueBody(r, "title", long)
+		assert.LessOrEqual(t, utf8.RuneCountInString(got), gitHub.MaxBodyLength())
+		assert.Contains(t, got, "The text was truncated, because it exceeds the limit of 65536 characters")
+	})
+	t.Run("should allow longer bodies for GitLab", func(t *testing.T) {
+		r := &Repo{Owner: "owner", Repo: "repo", Token: "token", UserID: "user", Vendor: gitLab}
+		assert.Equal(t, long, b.fitIssueBody(r, "title", long))
+	})
+	t.Run("should upload long body when enabled", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder(
+			"POST",
+			"https://api.github.com/gists",
+			httpmock.NewJsonResponderOrPanic(201, map[string]any{"html_url": "https://gist.github.com/1"}),
+		)
+		r := &Repo{Owner: "owner", Repo: "repo", Token: "token", UserID: "user", Vendor: gitHub, UploadLongBodies: true}
+		got := b.fitIssueBody(r, "title", long)
+		assert.LessOrEqual(t, utf8.RuneCountInString(got), gitHub.MaxBodyLength())
+		assert.True(t, strings.HasSuffix(got, "The full text is available [here](https://gist.github.com/1).*"))
+	})
+	t.Run("should truncate long body when upload fails", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder("POST", "https://api.github.com/gists", httpmock.NewStringResponder(http.StatusForbidden, ""))
+		r := &Repo{Owner: "owner", Repo: "repo", Token: "token", UserID: "user", Vendor: gitHub, UploadLongBodies: true}
+		got := b.fitIssueBody(r, "title", long)
+		assert.LessOrEqual(t, utf8.RuneCountInString(got), gitHub.MaxBodyLength())
+		assert.Contains(t, got, "because it exceeds the limit")
+	})
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"cmp"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -10,28 +12,310 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 const (
-	maxReposPerUser = 50
+	issueUndoTimeout     = 5 * time.Minute // how long a new issue can be closed again
+	maxComponentsPerPage = 10              // max 40 total allowed per message
+	maxHistoryIssues     = 25
+	maxReposPerGuild     = 50
+	maxReposPerUser      = 50
+	maxBasketMessages    = 10
+	maxDraftsPerUser     = 25
+	maxSelectOptions     = 25  // max options of a select menu allowed by Discord
+	maxTitleLength       = 256 // max length of issue titles on GitHub
+	maxTitlePrefixLength = 50
 )
 
 // Discord command names for interactions
 const (
+	cmdBasketAdd   = "Add to issue draft"
+	cmdIssue       = "issue"
 	cmdIssueCreate = "Create issue"
 	cmdManage      = "issuebot"
 )
 
+// Discord sub command names for the manage command
+const (
+	subcmdAdd         = "add"
+	subcmdChannel     = "channel"
+	subcmdCreate      = "create"
+	subcmdDefault     = "default"
+	subcmdDrafts      = "drafts"
+	subcmdFavorite    = "favorite"
+	subcmdForum       = "forum"
+	subcmdHistory     = "history"
+	subcmdIssue       = "issue"
+	subcmdList        = "list"
+	subcmdPermissions = "permissions"
+	subcmdReaction    = "reaction"
+	subcmdRemove      = "remove"
+	subcmdServer      = "server"
+	subcmdSettings    = "settings"
+	subcmdTemplate    = "template"
+	subcmdTest        = "test"
+	subcmdUpload      = "upload"
+)
+
+// Discord option names for commands
+const (
+	optApproval    = "approval"
+	optAttachment  = "attachment"
+	optChannel     = "channel"
+	optDescription = "description"
+	optEmoji       = "emoji"
+	optEnabled     = "enabled"
+	optForum       = "forum"
+	optIssue       = "issue"
+	optPermission  = "permission"
+	optRepo        = "repo"
+	optTarget      = "target"
+	optTitle       = "title"
+	optType        = "type"
+)
+
 // Discord commands
 var commands = []discordgo.ApplicationCommand{
 	{
 		Name:        cmdManage,
 		Description: "Manage repositories",
 		Type:        discordgo.ChatApplicationCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        subcmdList,
+				Description: "List and manage your repositories",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        subcmdAdd,
+				Description: "Add a repository",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        subcmdRemove,
+				Description: "Remove a repository",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         optRepo,
+						Description:  "Repository to remove",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Required:     true,
+						Autocomplete: true,
+					},
+				},
+			},
+			{
+				Name:        subcmdTest,
+				Description: "Test the access to a repository or all repositories",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         optRepo,
+						Description:  "Repository to test or \"all\"",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Required:     true,
+						Autocomplete: true,
+					},
+				},
+			},
+			{
+				Name:        subcmdTemplate,
+				Description: "Change the templates for the title and body of new issues of a repository",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         optRepo,
+						Description:  "Repository",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Required:     true,
+						Autocomplete: true,
+					},
+				},
+			},
+			{
+				Name:        subcmdUpload,
+				Description: "Upload the full text of too long issues as secret gist or private snippet",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         optRepo,
+						Description:  "Repository",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Required:     true,
+						Autocomplete: true,
+					},
+					{
+						Name:        optEnabled,
+						Description: "Whether too long issues are uploaded. Needs the gist scope for GitHub tokens",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Required:    true,
+					},
+				},
+			},
+			{
+				Name:        subcmdDefault,
+				Description: "Set the repository which is preselected when creating issues",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         optRepo,
+						Description:  "Default repository. Leave empty to remove the default",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Autocomplete: true,
+					},
+				},
+			},
+			{
+				Name:        subcmdDrafts,
+				Description: "Continue or discard your saved issue drafts",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        subcmdSettings,
+				Description: "Show your settings",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        subcmdHistory,
+				Description: "List issues you have created from Discord",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         optRepo,
+						Description:  "Show only issues of this repository",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Autocomplete: true,
+					},
+				},
+			},
+			{
+				Name:        subcmdFavorite,
+				Description: "Add or remove a repository from your favorites, which are shown first",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         optRepo,
+						Description:  "Repository",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Required:     true,
+						Autocomplete: true,
+					},
+				},
+			},
+			{
+				Name:        subcmdIssue,
+				Description: "Show the current status of an issue",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        optIssue,
+						Description: "URL of the issue or a reference like owner/repo#123",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+				},
+			},
+			{
+				Name:        subcmdServer,
+				Description: "Manage repositories shared with this server",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        subcmdChannel,
+				Description: "Set the default repository for issues created from a channel or category",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        optChannel,
+						Description: "Channel or category",
+						Type:        discordgo.ApplicationCommandOptionChannel,
+						Required:    true,
+						ChannelTypes: []discordgo.ChannelType{
+							discordgo.ChannelTypeGuildText,
+							discordgo.ChannelTypeGuildNews,
+							discordgo.ChannelTypeGuildForum,
+							discordgo.ChannelTypeGuildCategory,
+						},
+					},
+					{
+						Name:         optRepo,
+						Description:  "Shared repository to use by default. Leave empty to remove the default",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Autocomplete: true,
+					},
+				},
+			},
+			{
+				Name:        subcmdForum,
+				Description: "Turn new posts in a forum channel into issues",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         optForum,
+						Description:  "Forum channel to watch",
+						Type:         discordgo.ApplicationCommandOptionChannel,
+						Required:     true,
+						ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildForum},
+					},
+					{
+						Name:         optRepo,
+						Description:  "Shared repository for new issues. Leave empty to stop watching the forum",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Autocomplete: true,
+					},
+					{
+						Name:        optApproval,
+						Description: "Whether new posts are submitted for review first",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+					},
+				},
+			},
+			{
+				Name:        subcmdReaction,
+				Description: "Set the emoji for creating issues by reacting to a message",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        optEmoji,
+						Description: "Emoji, e.g. 🐛. Leave empty to remove the reaction",
+						Type:        discordgo.ApplicationCommandOptionString,
+					},
+				},
+			},
+			{
+				Name:        subcmdPermissions,
+				Description: "Show permissions for shared repositories or add a new rule",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        optPermission,
+						Description: "Permission to grant",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: permCreateIssues.Display(), Value: string(permCreateIssues)},
+							{Name: permSubmitIssues.Display(), Value: string(permSubmitIssues)},
+							{Name: permManageRepos.Display(), Value: string(permManageRepos)},
+						},
+					},
+					{
+						Name:        optTarget,
+						Description: "Role or user to grant the permission to",
+						Type:        discordgo.ApplicationCommandOptionMentionable,
+					},
+					{
+						Name:         optRepo,
+						Description:  "Grant the permission for this repository only",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Autocomplete: true,
+					},
+				},
+			},
+		},
 		IntegrationTypes: &[]discordgo.ApplicationIntegrationType{
 			discordgo.ApplicationIntegrationUserInstall,
 		},
@@ -53,17 +337,113 @@ var commands = []discordgo.ApplicationCommand{
 			discordgo.InteractionContextGuild,
 		},
 	},
+	{
+		Name: cmdBasketAdd,
+		Type: discordgo.MessageApplicationCommand,
+		IntegrationTypes: &[]discordgo.ApplicationIntegrationType{
+			discordgo.ApplicationIntegrationUserInstall,
+		},
+		Contexts: &[]discordgo.InteractionContextType{
+			discordgo.InteractionContextBotDM,
+			discordgo.InteractionContextPrivateChannel,
+			discordgo.InteractionContextGuild,
+		},
+	},
+	{
+		Name:        cmdIssue,
+		Description: "Create issues",
+		Type:        discordgo.ChatApplicationCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        subcmdCreate,
+				Description: "Create a new issue",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         optRepo,
+						Description:  "Repository for the new issue",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Required:     true,
+						Autocomplete: true,
+					},
+					{
+						Name:        optTitle,
+						Description: "Title of the issue",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+						MaxLength:   maxTitleLength,
+					},
+					{
+						Name:        optType,
+						Description: "Type of the issue",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Bug report", Value: int(bugReport)},
+							{Name: "Feature request", Value: int(featureRequest)},
+							{Name: "Other issue (no label)", Value: int(neutralIssue)},
+						},
+					},
+					{
+						Name:        optDescription,
+						Description: "Description of the issue",
+						Type:        discordgo.ApplicationCommandOptionString,
+					},
+					{
+						Name:        optAttachment,
+						Description: "File to attach to the issue, e.g. a screenshot",
+						Type:        discordgo.ApplicationCommandOptionAttachment,
+					},
+				},
+			},
+		},
+		IntegrationTypes: &[]discordgo.ApplicationIntegrationType{
+			discordgo.ApplicationIntegrationUserInstall,
+		},
+		Contexts: &[]discordgo.InteractionContextType{
+			discordgo.InteractionContextBotDM,
+			discordgo.InteractionContextPrivateChannel,
+			discordgo.InteractionContextGuild,
+		},
+	},
 }
 
 // Discord custom IDs for interactions
 const (
-	idIssueCreateIssue1 = "issueCreateIssue1-"
-	idIssueCreateIssue2 = "issueCreateIssue2-"
-	idIssueCreateIssue3 = "issueCreateIssue3-"
-	idRepoAdd1          = "repoAdd1"
-	idRepoAdd2          = "repoAdd2-"
-	idRepoDelete        = "repoDelete-"
-	idRepoTest          = "repoTest-"
+	idGuildRepoAdd1      = "guildRepoAdd1"
+	idGuildRepoAdd2      = "guildRepoAdd2"
+	idGuildReviewChannel = "guildReviewChannel"
+	idIssueAddLabel1     = "issueAddLabel1-"
+	idIssueAddLabel2     = "issueAddLabel2-"
+	idIssueAssign        = "issueAssign-"
+	idIssueClose         = "issueClose-"
+	idIssueComment1      = "issueComment1-"
+	idIssueComment2      = "issueComment2-"
+	idBasketClear        = "basketClear"
+	idBasketCreate       = "basketCreate"
+	idDraftDiscard       = "draftDiscard-"
+	idDraftEdit          = "draftEdit-"
+	idDraftResume        = "draftResume-"
+	idIssueCreateChange  = "issueCreateChange-"
+	idIssueCreateDraft   = "issueCreateDraft-"
+	idIssueCreateEdit    = "issueCreateEdit-"
+	idIssueCreateIssue1  = "issueCreateIssue1-"
+	idIssueCreateIssue2  = "issueCreateIssue2-"
+	idIssueCreateIssue3  = "issueCreateIssue3-"
+	idIssueCreatePage    = "issueCreatePage-"
+	idIssueCreateProceed = "issueCreateProceed-"
+	idIssueCreateSearch1 = "issueCreateSearch1-"
+	idIssueCreateSearch2 = "issueCreateSearch2-"
+	idIssueCreateSubmit  = "issueCreateSubmit-"
+	idIssueCreateType    = "issueCreateType-"
+	idIssuePost          = "issuePost-"
+	idPermissionRemove   = "permissionRemove-"
+	idRepoAdd1           = "repoAdd1"
+	idRepoAdd2           = "repoAdd2"
+	idRepoDelete         = "repoDelete-"
+	idRepoTemplate       = "repoTemplate-"
+	idRepoTest           = "repoTest-"
+	idSubmissionApprove  = "submissionApprove-"
+	idSubmissionReject   = "submissionReject-"
 )
 
 type issueType int
@@ -86,18 +466,39 @@ func (it issueType) Display() string {
 	return ""
 }
 
+// Labels returns the labels for issues of this type.
+func (it issueType) Labels() []string {
+	switch it {
+	case bugReport:
+		return []string{"bug"}
+	case featureRequest:
+		return []string{"enhancement"}
+	}
+	return nil
+}
+
 // createIssueData represents the data of an interaction session.
 type createIssueData struct {
 	authorID         string
+	attachments      []Attachment // attachments of the message, which are added to the content when creating the issue
 	authorName       string
+	basket           []BasketMessage // messages for creating an issue from several messages
 	channelID        string
+	description      string
+	draftID          int // ID of the draft the session was resumed from
 	guildID          string
+	hasPreview       bool // whether the details have been entered and the preview was shown
 	issueType        issueType
 	messageContent   string
 	messageID        string
 	messageTimestamp time.Time
+	member           *discordgo.Member // guild member for sessions started outside of an interaction, e.g. by reactions
 	repoID           int
+	repoPage         int    // current page of the repo picker
+	repoSearch       string // current search of the repo picker
+	threadID         string
 	title            string
+	userID           string // Discord user who started the session
 }
 
 var (
@@ -106,81 +507,210 @@ var (
 )
 
 type Bot struct {
-	api      *repoAPI
-	appID    string
-	counter  atomic.Int64
-	ds       *discordgo.Session
-	sessions sync.Map
-	st       *Storage
+	api          *repoAPI
+	appID        string
+	ds           *discordgo.Session
+	guildInstall bool // whether the app can be installed to guilds
+	sessions     *sessionStore
+	st           *Storage
+	templates    IssueTemplates // global templates for new issues
 }
 
-func NewBot(st *Storage, ds *discordgo.Session, appID string, api *repoAPI) *Bot {
+func NewBot(st *Storage, ds *discordgo.Session, appID string, api *repoAPI, guildInstall bool, templates IssueTemplates) *Bot {
 	b := &Bot{
-		api:   api,
-		appID: appID,
-		ds:    ds,
-		st:    st,
+		api:          api,
+		appID:        appID,
+		ds:           ds,
+		guildInstall: guildInstall,
+		sessions:     newSessionStore(st),
+		st:           st,
+		templates:    templates,
 	}
 	ds.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		slog.Info("Bot is up", "appID", appID)
 	})
 	ds.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if err := b.handleInteraction(i); err != nil {
+		err := b.handleInteraction(i)
+		var content string
+		if errors.Is(err, errSessionExpired) {
+			content = ":hourglass: This request has expired. Please start again."
+		} else if errors.Is(err, errSessionForbidden) {
+			content = ":x: This request was started by another user"
+		}
+		if content != "" {
+			err = b.ds.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: content,
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+		}
+		if err != nil {
 			slog.Error("interaction failed", "error", err)
 		}
 	})
+	ds.AddHandler(func(s *discordgo.Session, tc *discordgo.ThreadCreate) {
+		if err := b.handleThreadCreate(tc); err != nil {
+			slog.Error("thread create failed", "threadID", tc.ID, "error", err)
+		}
+	})
+	ds.AddHandler(func(s *discordgo.Session, mr *discordgo.MessageReactionAdd) {
+		if err := b.handleMessageReactionAdd(mr); err != nil {
+			slog.Error("message reaction add failed", "messageID", mr.MessageID, "error", err)
+		}
+	})
+	ds.AddHandler(func(s *discordgo.Session, mc *discordgo.MessageCreate) {
+		if err := b.handleMessageCreate(mc); err != nil {
+			slog.Error("message create failed", "messageID", mc.ID, "error", err)
+		}
+	})
 	return b
 }
 
-func (b *Bot) InitCommands(isReset bool) error {
-	cc, err := b.ds.ApplicationCommands(b.appID, "")
+// InitCommands syncs the Discord commands with their definitions.
+// Commands are created, updated or deleted individually, so users do not need to re-install the app.
+// When guildID is set, commands are registered for that guild only, which makes changes visible instantly.
+func (b *Bot) InitCommands(guildID string) error {
+	current, err := b.ds.ApplicationCommands(b.appID, guildID)
 	if err != nil {
 		return err
 	}
-	hasCommands := len(cc) > 0
-	if hasCommands && isReset {
-		// Delete existing commands
-		for _, cmd := range cc {
-			err := b.ds.ApplicationCommandDelete(b.appID, "", cmd.ID)
-			if err != nil {
-				return fmt.Errorf("delete application command %s: %w", cmd.Name, err)
-			}
-			slog.Info("Deleted application command", "cmd", cmd.Name)
+	desired := b.makeCommands(guildID)
+	create, update, remove := diffCommands(current, desired)
+	for _, cmd := range create {
+		if _, err := b.ds.ApplicationCommandCreate(b.appID, guildID, cmd); err != nil {
+			return fmt.Errorf("create application command %s: %w", cmd.Name, err)
 		}
+		slog.Info("Created application command", "cmd", cmd.Name, "guildID", guildID)
 	}
-	if !hasCommands || isReset {
-		// Add commands
-		for _, cmd := range commands {
-			_, err := b.ds.ApplicationCommandCreate(b.appID, "", &cmd)
-			if err != nil {
-				return fmt.Errorf("create application command %s: %w", cmd.Name, err)
-			}
-			slog.Info("Created application command", "cmd", cmd.Name)
+	for _, cmd := range update {
+		if _, err := b.ds.ApplicationCommandEdit(b.appID, guildID, cmd.ID, cmd); err != nil {
+			return fmt.Errorf("update application command %s: %w", cmd.Name, err)
+		}
+		slog.Info("Updated application command", "cmd", cmd.Name, "guildID", guildID)
+	}
+	for _, cmd := range remove {
+		if err := b.ds.ApplicationCommandDelete(b.appID, guildID, cmd.ID); err != nil {
+			return fmt.Errorf("delete application command %s: %w", cmd.Name, err)
 		}
+		slog.Info("Deleted application command", "cmd", cmd.Name, "guildID", guildID)
 	}
 	return nil
 }
 
-func (b *Bot) handleInteraction(ic *discordgo.InteractionCreate) error {
-	respondWithMessage := func(content string) error {
-		err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: content,
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
+// makeCommands returns the definitions of all commands for registering them globally
+// or for the guild with guildID.
+func (b *Bot) makeCommands(guildID string) []*discordgo.ApplicationCommand {
+	var cmds []*discordgo.ApplicationCommand
+	for _, cmd := range commands {
+		if guildID != "" {
+			// guild commands can only be used in their guild
+			cmd.IntegrationTypes = &[]discordgo.ApplicationIntegrationType{discordgo.ApplicationIntegrationGuildInstall}
+			cmd.Contexts = &[]discordgo.InteractionContextType{discordgo.InteractionContextGuild}
+		} else if b.guildInstall {
+			x := slices.Clone(*cmd.IntegrationTypes)
+			x = append(x, discordgo.ApplicationIntegrationGuildInstall)
+			cmd.IntegrationTypes = &x
+		}
+		cmds = append(cmds, &cmd)
+	}
+	return cmds
+}
+
+// diffCommands compares the current commands with the desired commands.
+// It returns the commands to create, the commands to update with their IDs set
+// and the current commands to delete.
+func diffCommands(current, desired []*discordgo.ApplicationCommand) (create, update, remove []*discordgo.ApplicationCommand) {
+	key := func(cmd *discordgo.ApplicationCommand) string {
+		return fmt.Sprintf("%d-%s", cmp.Or(cmd.Type, discordgo.ChatApplicationCommand), cmd.Name)
+	}
+	m := make(map[string]*discordgo.ApplicationCommand)
+	for _, cmd := range current {
+		m[key(cmd)] = cmd
+	}
+	for _, cmd := range desired {
+		c, ok := m[key(cmd)]
+		if !ok {
+			create = append(create, cmd)
+			continue
+		}
+		delete(m, key(cmd))
+		if !commandsEqual(c, cmd) {
+			x := *cmd
+			x.ID = c.ID
+			update = append(update, &x)
+		}
+	}
+	for _, cmd := range current {
+		if _, ok := m[key(cmd)]; ok {
+			remove = append(remove, cmd)
+		}
+	}
+	return create, update, remove
+}
+
+// commandsEqual reports whether two command definitions are the same.
+// Fields which are assigned by Discord, like IDs and versions, are ignored.
+func commandsEqual(a, b *discordgo.ApplicationCommand) bool {
+	if cmp.Or(a.Type, discordgo.ChatApplicationCommand) != cmp.Or(b.Type, discordgo.ChatApplicationCommand) {
+		return false
+	}
+	if a.Name != b.Name || a.Description != b.Description {
+		return false
+	}
+	if !sameElements(a.IntegrationTypes, b.IntegrationTypes) || !sameElements(a.Contexts, b.Contexts) {
+		return false
+	}
+	return optionsEqual(a.Options, b.Options)
+}
+
+// optionsEqual reports whether two lists of command options are the same.
+func optionsEqual(a, b []*discordgo.ApplicationCommandOption) bool {
+	return slices.EqualFunc(a, b, func(x, y *discordgo.ApplicationCommandOption) bool {
+		if x.Type != y.Type || x.Name != y.Name || x.Description != y.Description {
+			return false
+		}
+		if x.Required != y.Required || x.Autocomplete != y.Autocomplete || x.MaxLength != y.MaxLength {
+			return false
+		}
+		if !slices.Equal(x.ChannelTypes, y.ChannelTypes) {
+			return false
+		}
+		// choice values are numbers of different types after decoding from JSON
+		equalChoices := slices.EqualFunc(x.Choices, y.Choices, func(c, d *discordgo.ApplicationCommandOptionChoice) bool {
+			return c.Name == d.Name && fmt.Sprint(c.Value) == fmt.Sprint(d.Value)
 		})
-		return err
+		if !equalChoices {
+			return false
+		}
+		return optionsEqual(x.Options, y.Options)
+	})
+}
+
+// sameElements reports whether two optional lists contain the same elements in any order.
+func sameElements[T cmp.Ordered](a, b *[]T) bool {
+	var x, y []T
+	if a != nil {
+		x = slices.Sorted(slices.Values(*a))
 	}
+	if b != nil {
+		y = slices.Sorted(slices.Values(*b))
+	}
+	return slices.Equal(x, y)
+}
+
+func (b *Bot) handleInteraction(ic *discordgo.InteractionCreate) error {
 
-	var userID string
+	var user *discordgo.User
 	if ic.Member != nil && ic.Member.User != nil {
-		userID = ic.Member.User.ID
+		user = ic.Member.User
 	} else if ic.User != nil {
-		userID = ic.User.ID
+		user = ic.User
 	} else {
 		return fmt.Errorf("no user found for interaction")
 	}
+	userID := user.ID
 
 	switch ic.Type {
 	case discordgo.InteractionApplicationCommand:
@@ -193,197 +723,468 @@ func (b *Bot) handleInteraction(ic *discordgo.InteractionCreate) error {
 			message := data.Resolved.Messages[messageID]
 			s := createIssueData{
 				authorID:         message.Author.ID,
+				attachments:      makeAttachments(message),
 				authorName:       message.Author.Username,
 				channelID:        ic.ChannelID,
 				guildID:          ic.GuildID,
-				messageContent:   message.Content,
+				messageContent:   b.makeMessageContent(ic.GuildID, message, data.Resolved),
 				messageID:        message.ID,
 				messageTimestamp: message.Timestamp,
+				userID:           userID,
+			}
+			if b.isGuildInstalled(ic) {
+				if c, err := b.channel(ic.ChannelID); err == nil && c.IsThread() {
+					s.threadID = c.ID
+				}
 			}
 			sessionID := b.newSessionID()
-			b.sessions.Store(sessionID, s)
-			repos, err := b.st.ListReposForUser(userID)
+			if err := b.sessions.Store(sessionID, s); err != nil {
+				return err
+			}
+			issues, err := b.st.ListIssuesForMessage(messageID)
 			if err != nil {
 				return err
 			}
-			if len(repos) == 0 {
-				return respondWithMessage(":exclamation: Please add a repo")
+			var d *discordgo.InteractionResponseData
+			if len(issues) > 0 {
+				d, err = b.makeDuplicateIssuesData(ic, userID, sessionID, issues)
+			} else {
+				d, err = b.makeCreateIssueStartData(ic, userID, sessionID)
 			}
-			options := make([]discordgo.SelectMenuOption, 0)
-			for _, r := range repos {
-				options = append(options, discordgo.SelectMenuOption{
-					Label: r.Name(),
-					Value: strconv.Itoa(r.ID),
-				})
+			if err != nil {
+				return err
 			}
 			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "Create issue [1 / 3]",
-					Flags:   discordgo.MessageFlagsEphemeral,
-					Components: []discordgo.MessageComponent{
-						discordgo.ActionsRow{
-							Components: []discordgo.MessageComponent{
-								discordgo.SelectMenu{
-									CustomID:    idIssueCreateIssue1 + sessionID,
-									Options:     options,
-									Placeholder: "Choose repo",
-								},
-							},
-						},
-					},
-				},
+				Data: d,
 			})
 			return err
 
-		case cmdManage:
-			err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Flags: discordgo.MessageFlagsEphemeral,
-				},
-			})
-			if err != nil {
-				return err
-			}
-			repos, err := b.st.ListReposForUser(userID)
-			if err != nil {
-				return err
+		case cmdBasketAdd:
+			return b.addToBasket(ic, userID, data.Resolved.Messages[data.TargetID])
+
+		case cmdIssue:
+			if len(data.Options) == 0 {
+				return fmt.Errorf("missing sub command for %s", name)
 			}
-			components := []discordgo.MessageComponent{discordgo.TextDisplay{
-				Content: fmt.Sprintf("%d repos", len(repos)),
-			}}
-			for _, r := range repos {
-				container := discordgo.Container{
-					Components: []discordgo.MessageComponent{
-						discordgo.TextDisplay{
-							Content: fmt.Sprintf("[%s](%s)", r.Name(), r.URL()),
-						},
-						discordgo.ActionsRow{
-							Components: []discordgo.MessageComponent{
-								discordgo.Button{
-									CustomID: fmt.Sprintf("%s%d", idRepoDelete, r.ID),
-									Label:    "Remove",
-									Style:    discordgo.DangerButton,
-								},
-								discordgo.Button{
-									CustomID: fmt.Sprintf("%s%d", idRepoTest, r.ID),
-									Label:    "Test",
-								},
-							},
-						},
-					},
-				}
-				components = append(components, container)
+			sub := data.Options[0]
+			switch sub.Name {
+			case subcmdCreate:
+				return b.createIssueFromCommand(ic, user, sub)
 			}
-			components = append(components, discordgo.ActionsRow{
-				Components: []discordgo.MessageComponent{
-					discordgo.Button{
-						CustomID: idRepoAdd1,
-						Label:    "Add repository",
-					},
-				},
-			})
+			return fmt.Errorf("unhandled sub command for %s: %s", name, sub.Name)
 
-			const maxComponentsPerPage = 10 // max 40 total allowed per message
-			for chunk := range slices.Chunk(components, maxComponentsPerPage) {
-				_, err = b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
-					Flags:      discordgo.MessageFlagsEphemeral | discordgo.MessageFlagsIsComponentsV2,
-					Components: chunk,
-				})
+		case cmdManage:
+			if len(data.Options) == 0 {
+				return fmt.Errorf("missing sub command for %s", name)
+			}
+			sub := data.Options[0]
+			switch sub.Name {
+			case subcmdList:
+				return b.showRepos(ic, userID)
+			case subcmdAdd:
+				return b.startAddRepo(ic, userID)
+			case subcmdRemove:
+				o := sub.GetOption(optRepo)
+				if o == nil {
+					return fmt.Errorf("missing option for %s: %s", sub.Name, optRepo)
+				}
+				repoID, err := strconv.Atoi(o.StringValue())
+				if err != nil {
+					return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+				}
+				return b.removeRepo(ic, userID, repoID)
+			case subcmdTest:
+				o := sub.GetOption(optRepo)
+				if o == nil {
+					return fmt.Errorf("missing option for %s: %s", sub.Name, optRepo)
+				}
+				repos, err := b.listManageableRepos(ic, userID)
+				if err != nil {
+					return err
+				}
+				if strings.ToLower(o.StringValue()) != testAllRepos {
+					r := findRepoByOption(repos, o.StringValue())
+					if r == nil {
+						return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+					}
+					repos = []*Repo{r}
+				}
+				return b.testRepos(ic, repos)
+			case subcmdTemplate:
+				o := sub.GetOption(optRepo)
+				if o == nil {
+					return fmt.Errorf("missing option for %s: %s", sub.Name, optRepo)
+				}
+				repoID, err := strconv.Atoi(o.StringValue())
+				if err != nil {
+					return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+				}
+				return b.startEditTemplates(ic, userID, repoID)
+			case subcmdUpload:
+				o := sub.GetOption(optRepo)
+				if o == nil {
+					return fmt.Errorf("missing option for %s: %s", sub.Name, optRepo)
+				}
+				o2 := sub.GetOption(optEnabled)
+				if o2 == nil {
+					return fmt.Errorf("missing option for %s: %s", sub.Name, optEnabled)
+				}
+				repoID, err := strconv.Atoi(o.StringValue())
+				if err != nil {
+					return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+				}
+				return b.setUploadLongBodies(ic, userID, repoID, o2.BoolValue())
+			case subcmdDefault:
+				return b.setDefaultRepo(ic, userID, sub)
+			case subcmdSettings:
+				return b.showSettings(ic, userID)
+			case subcmdDrafts:
+				return b.showDrafts(ic, userID)
+			case subcmdHistory:
+				var repoID int
+				if o := sub.GetOption(optRepo); o != nil {
+					x, err := strconv.Atoi(o.StringValue())
+					if err != nil {
+						return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+					}
+					repoID = x
+				}
+				return b.showHistory(ic, userID, repoID)
+			case subcmdFavorite:
+				o := sub.GetOption(optRepo)
+				if o == nil {
+					return fmt.Errorf("missing option for %s: %s", sub.Name, optRepo)
+				}
+				repos, err := b.listReposForInteraction(ic, userID)
+				if err != nil {
+					return err
+				}
+				r := findRepoByOption(repos, o.StringValue())
+				if r == nil {
+					return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+				}
+				u, err := b.st.GetUser(userID)
+				if err != nil {
+					return err
+				}
+				isFavorite := u.ToggleFavorite(r.ID)
+				if err := b.st.UpdateUser(u); err != nil {
+					return err
+				}
+				if isFavorite {
+					return b.respondEphemeral(ic, fmt.Sprintf(":star: %s added to your favorites", r.Name()))
+				}
+				return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: %s removed from your favorites", r.Name()))
+			case subcmdIssue:
+				o := sub.GetOption(optIssue)
+				if o == nil {
+					return fmt.Errorf("missing option for %s: %s", sub.Name, optIssue)
+				}
+				return b.showIssue(ic, userID, o.StringValue())
+			case subcmdServer:
+				if !b.isGuildInstalled(ic) {
+					return b.respondEphemeral(ic, ":x: Shared repos require issuebot to be installed on this server")
+				}
+				ok, err := b.hasPermission(ic, permManageRepos, 0)
 				if err != nil {
 					return err
 				}
+				if !ok {
+					return b.respondEphemeral(ic, ":x: You are not permitted to manage shared repos")
+				}
+				return b.showGuildRepos(ic)
+			case subcmdChannel:
+				if !b.isGuildInstalled(ic) {
+					return b.respondEphemeral(ic, ":x: Default repos require issuebot to be installed on this server")
+				}
+				o := sub.GetOption(optChannel)
+				if o == nil {
+					return fmt.Errorf("missing option for %s: %s", sub.Name, optChannel)
+				}
+				channelID := o.Value.(string)
+				var r *Repo
+				if o := sub.GetOption(optRepo); o != nil {
+					x, err := strconv.Atoi(o.StringValue())
+					if err != nil {
+						return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+					}
+					r, err = b.st.GetRepo(x)
+					if errors.Is(err, ErrNotFound) || (err == nil && r.GuildID != ic.GuildID) {
+						return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+					} else if err != nil {
+						return err
+					}
+				}
+				g, err := b.st.GetGuild(ic.GuildID)
+				if err != nil {
+					return err
+				}
+				var repoID int
+				if r != nil {
+					repoID = r.ID
+				}
+				currentID, found := g.ChannelRepo(channelID)
+				if repoID == 0 && !found {
+					return b.respondEphemeral(ic, fmt.Sprintf(":x: <#%s> has no default repo", channelID))
+				}
+				for _, id := range []int{currentID, repoID} {
+					if id == 0 {
+						continue
+					}
+					ok, err := b.hasPermission(ic, permManageRepos, id)
+					if err != nil {
+						return err
+					}
+					if !ok {
+						return b.respondEphemeral(ic, ":x: You are not permitted to manage this repo")
+					}
+				}
+				g.SetChannelRepo(channelID, repoID)
+				if err := b.st.UpdateGuild(g); err != nil {
+					return err
+				}
+				if r == nil {
+					return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: Default repo removed from <#%s>", channelID))
+				}
+				return b.respondEphemeral(ic, fmt.Sprintf(
+					":white_check_mark: Issues created from <#%s> will use %s by default", channelID, r.Name(),
+				))
+			case subcmdForum:
+				return b.setForum(ic, sub)
+			case subcmdReaction:
+				return b.setReactionEmoji(ic, sub)
+			case subcmdPermissions:
+				if !b.isGuildInstalled(ic) {
+					return b.respondEphemeral(ic, ":x: Permissions require issuebot to be installed on this server")
+				}
+				if !isGuildAdmin(ic) {
+					return b.respondEphemeral(ic, ":x: Only server managers can change permissions")
+				}
+				var rule PermissionRule
+				if o := sub.GetOption(optPermission); o != nil {
+					rule.Permission = Permission(o.StringValue())
+				}
+				if o := sub.GetOption(optTarget); o != nil {
+					id := o.Value.(string)
+					if _, ok := data.Resolved.Roles[id]; ok {
+						rule.RoleID = id
+					} else {
+						rule.UserID = id
+					}
+				}
+				if o := sub.GetOption(optRepo); o != nil {
+					x, err := strconv.Atoi(o.StringValue())
+					if err != nil {
+						return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+					}
+					r, err := b.st.GetRepo(x)
+					if errors.Is(err, ErrNotFound) || (err == nil && r.GuildID != ic.GuildID) {
+						return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+					} else if err != nil {
+						return err
+					}
+					rule.RepoID = r.ID
+				}
+				hasTarget := rule.RoleID != "" || rule.UserID != ""
+				if rule.Permission != "" && hasTarget {
+					g, err := b.st.GetGuild(ic.GuildID)
+					if err != nil {
+						return err
+					}
+					g.AddRule(rule)
+					if err := b.st.UpdateGuild(g); err != nil {
+						return err
+					}
+				} else if rule.Permission != "" || hasTarget || rule.RepoID != 0 {
+					return b.respondEphemeral(ic, ":x: Please provide a permission and a role or user to add a rule")
+				}
+				return b.showPermissions(ic)
 			}
-
-			return nil
+			return fmt.Errorf("unhandled sub command for %s: %s", name, sub.Name)
 		}
 		return fmt.Errorf("unhandled application command: %s", name)
 
-	case discordgo.InteractionMessageComponent:
-		data := ic.MessageComponentData()
-		customID := data.CustomID
-
-		if customID == idRepoAdd1 {
-			n, err := b.st.CountReposForUser(userID)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		data := ic.ApplicationCommandData()
+		o := findFocusedOption(data.Options)
+		if o == nil {
+			return fmt.Errorf("no focused option for autocomplete: %s", data.Name)
+		}
+		var choices []*discordgo.ApplicationCommandOptionChoice
+		switch o.Name {
+		case optRepo:
+			var repos []*Repo
+			var err error
+			if len(data.Options) > 0 && data.Options[0].Name == subcmdPermissions {
+				if b.isGuildInstalled(ic) && isGuildAdmin(ic) {
+					repos, err = b.st.ListReposForGuild(ic.GuildID)
+				}
+			} else if len(data.Options) > 0 && (data.Options[0].Name == subcmdChannel || data.Options[0].Name == subcmdForum) {
+				repos, err = b.listManagedGuildRepos(ic)
+			} else if len(data.Options) > 0 && slices.Contains([]string{subcmdRemove, subcmdTemplate, subcmdTest, subcmdUpload}, data.Options[0].Name) {
+				repos, err = b.listManageableRepos(ic, userID)
+			} else {
+				repos, err = b.listReposForInteraction(ic, userID)
+				if err == nil {
+					repos, _, err = b.sortReposForUser(userID, repos)
+				}
+			}
 			if err != nil {
 				return err
 			}
-			if n >= maxReposPerUser {
-				return respondWithMessage("You have reached the upper limit of repos")
+			choices = makeRepoChoices(repos, o.StringValue())
+			if len(data.Options) > 0 && data.Options[0].Name == subcmdTest && len(choices) < maxSelectOptions {
+				choices = append([]*discordgo.ApplicationCommandOptionChoice{
+					{Name: "All repositories", Value: testAllRepos},
+				}, choices...)
+			}
+		default:
+			return fmt.Errorf("unhandled autocomplete option for %s: %s", data.Name, o.Name)
+		}
+		err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+			Data: &discordgo.InteractionResponseData{
+				Choices: choices,
+			},
+		})
+		return err
+
+	case discordgo.InteractionMessageComponent:
+		data := ic.MessageComponentData()
+		customID := data.CustomID
+
+		if customID == idRepoAdd1 {
+			return b.startAddRepo(ic, userID)
+
+		} else if customID == idGuildRepoAdd1 {
+			ok, err := b.hasPermission(ic, permManageRepos, 0)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return b.respondEphemeral(ic, ":x: You are not permitted to add shared repos")
+			}
+			repos, err := b.st.ListReposForGuild(ic.GuildID)
+			if err != nil {
+				return err
+			}
+			if len(repos) >= maxReposPerGuild {
+				return b.respondEphemeral(ic, "This server has reached the upper limit of repos")
 			}
-
 			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseModal,
-				Data: &discordgo.InteractionResponseData{
-					CustomID: idRepoAdd2 + userID,
-					Title:    "Add repo",
-					Components: []discordgo.MessageComponent{
-						discordgo.ActionsRow{
-							Components: []discordgo.MessageComponent{
-								discordgo.TextInput{
-									CustomID:    "url",
-									Label:       "Repository URL",
-									Placeholder: "https://github.com/{OWNER}/{REPO}",
-									Required:    true,
-									Style:       discordgo.TextInputShort,
-								},
-							},
-						},
-						discordgo.ActionsRow{
-							Components: []discordgo.MessageComponent{
-								discordgo.TextInput{
-									CustomID:    "token",
-									Label:       "Token",
-									Placeholder: "Token with permission to read & write issues",
-									Required:    true,
-									Style:       discordgo.TextInputShort,
-								},
-							},
-						},
-					},
-				},
+				Data: makeRepoAddModalData(idGuildRepoAdd2),
 			})
 			return err
 
-		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateIssue1); found {
-			x, ok := b.sessions.Load(sessionID)
+		} else if customID == idGuildReviewChannel {
+			ok, err := b.hasPermission(ic, permManageRepos, 0)
+			if err != nil {
+				return err
+			}
 			if !ok {
-				return fmt.Errorf("failed to load session")
+				return b.respondEphemeral(ic, ":x: You are not permitted to change the review channel")
 			}
-			s := x.(createIssueData)
-			idx, err := strconv.Atoi(data.Values[0])
+			g, err := b.st.GetGuild(ic.GuildID)
 			if err != nil {
 				return err
 			}
-			s.repoID = idx
-			b.sessions.Store(sessionID, s)
-			options := []discordgo.SelectMenuOption{
-				{
-					Label: "Bug report",
-					Value: strconv.Itoa(int(bugReport)),
-				},
-				{
-					Label: "Feature request",
-					Value: strconv.Itoa(int(featureRequest)),
-				},
-				{
-					Label: "Other issue (no label)",
-					Value: strconv.Itoa(int(neutralIssue)),
-				},
+			g.ReviewChannelID = ""
+			if len(data.Values) > 0 {
+				g.ReviewChannelID = data.Values[0]
+			}
+			if err := b.st.UpdateGuild(g); err != nil {
+				return err
+			}
+			if g.ReviewChannelID == "" {
+				return b.respondEphemeral(ic, ":white_check_mark: Review channel removed")
+			}
+			return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: Review channel set to <#%s>", g.ReviewChannelID))
+
+		} else if x, found := strings.CutPrefix(customID, idPermissionRemove); found {
+			if !b.isGuildInstalled(ic) || !isGuildAdmin(ic) {
+				return b.respondEphemeral(ic, ":x: Only server managers can change permissions")
+			}
+			ruleID, err := strconv.Atoi(x)
+			if err != nil {
+				return err
+			}
+			g, err := b.st.GetGuild(ic.GuildID)
+			if err != nil {
+				return err
+			}
+			if !g.RemoveRule(ruleID) {
+				return b.respondEphemeral(ic, ":x: Rule not found")
+			}
+			if err := b.st.UpdateGuild(g); err != nil {
+				return err
+			}
+			return b.respondEphemeral(ic, ":white_check_mark: Rule removed")
+
+		} else if x, found := strings.CutPrefix(customID, idSubmissionApprove); found {
+			return b.reviewSubmission(ic, x, true)
+
+		} else if x, found := strings.CutPrefix(customID, idSubmissionReject); found {
+			return b.reviewSubmission(ic, x, false)
+
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateProceed); found {
+			if _, err := b.sessions.Load(sessionID, userID); err != nil {
+				return err
+			}
+			d, err := b.makeCreateIssueStartData(ic, userID, sessionID)
+			if err != nil {
+				return err
+			}
+			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseUpdateMessage,
+				Data: d,
+			})
+			return err
+
+		} else if x, found := strings.CutPrefix(customID, idIssueCreatePage); found {
+			sessionID, p, _ := strings.Cut(x, "-")
+			page, err := strconv.Atoi(p)
+			if err != nil {
+				return err
+			}
+			s, err := b.sessions.Load(sessionID, userID)
+			if err != nil {
+				return err
+			}
+			s.repoPage = page
+			if err := b.sessions.Store(sessionID, s); err != nil {
+				return err
+			}
+			d, err := b.makeRepoPickerData(ic, userID, sessionID)
+			if err != nil {
+				return err
 			}
 			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseUpdateMessage,
+				Data: d,
+			})
+			return err
+
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateSearch1); found {
+			s, err := b.sessions.Load(sessionID, userID)
+			if err != nil {
+				return err
+			}
+			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseModal,
 				Data: &discordgo.InteractionResponseData{
-					Content: "Create issue [2 / 3]",
-					Flags:   discordgo.MessageFlagsEphemeral,
+					CustomID: idIssueCreateSearch2 + sessionID,
+					Title:    "Search repos",
 					Components: []discordgo.MessageComponent{
 						discordgo.ActionsRow{
 							Components: []discordgo.MessageComponent{
-								discordgo.SelectMenu{
-									CustomID:    idIssueCreateIssue2 + sessionID,
-									Options:     options,
-									Placeholder: "Choose issue type",
+								discordgo.TextInput{
+									CustomID:    "search",
+									Label:       "Name contains",
+									Placeholder: "Leave empty to show all repos",
+									Style:       discordgo.TextInputShort,
+									Value:       s.repoSearch,
 								},
 							},
 						},
@@ -392,40 +1193,41 @@ func (b *Bot) handleInteraction(ic *discordgo.InteractionCreate) error {
 			})
 			return err
 
-		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateIssue2); found {
-			x, ok := b.sessions.Load(sessionID)
-			if !ok {
-				return fmt.Errorf("failed to load session")
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateChange); found {
+			if _, err := b.sessions.Load(sessionID, userID); err != nil {
+				return err
 			}
-			s := x.(createIssueData)
-			idx, err := strconv.Atoi(data.Values[0])
+			d, err := b.makeRepoPickerData(ic, userID, sessionID)
 			if err != nil {
 				return err
 			}
-			s.issueType = issueType(idx)
-			b.sessions.Store(sessionID, s)
+			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseUpdateMessage,
+				Data: d,
+			})
+			return err
+
+		} else if x, found := strings.CutPrefix(customID, idIssueAddLabel1); found {
+			it, _, err := b.loadIssueForAction(ic, x, userID)
+			if errors.Is(err, ErrNotFound) {
+				return b.respondEphemeral(ic, ":x: You have no access to this issue")
+			} else if err != nil {
+				return err
+			}
 			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseModal,
 				Data: &discordgo.InteractionResponseData{
-					CustomID: idIssueCreateIssue3 + sessionID,
-					Title:    "Create issue [3 / 3]",
+					CustomID: fmt.Sprintf("%s%d", idIssueAddLabel2, it.ID),
+					Title:    fmt.Sprintf("Add labels to #%d", it.Number),
 					Components: []discordgo.MessageComponent{
 						discordgo.ActionsRow{
 							Components: []discordgo.MessageComponent{
 								discordgo.TextInput{
-									CustomID: "title",
-									Label:    "Title",
-									Style:    discordgo.TextInputShort,
-									Required: true,
-								},
-							},
-						},
-						discordgo.ActionsRow{
-							Components: []discordgo.MessageComponent{
-								discordgo.TextInput{
-									CustomID: "description",
-									Label:    "Description",
-									Style:    discordgo.TextInputParagraph,
+									CustomID:    "labels",
+									Label:       "Labels",
+									Placeholder: "Comma separated list, e.g. bug, documentation",
+									Style:       discordgo.TextInputShort,
+									Required:    true,
 								},
 							},
 						},
@@ -434,218 +1236,2058 @@ func (b *Bot) handleInteraction(ic *discordgo.InteractionCreate) error {
 			})
 			return err
 
-		} else if x, found := strings.CutPrefix(customID, idRepoDelete); found {
-			repoID, err := strconv.Atoi(x)
-			if err != nil {
+		} else if x, found := strings.CutPrefix(customID, idIssueAssign); found {
+			it, r, err := b.loadIssueForAction(ic, x, userID)
+			if errors.Is(err, ErrNotFound) {
+				return b.respondEphemeral(ic, ":x: You have no access to this issue")
+			} else if err != nil {
+				return err
+			}
+			if err := b.api.assignToTokenOwner(r, it.Number); err != nil {
+				slog.Warn("Failed to assign issue", "url", it.URL, "error", err)
+				return b.respondEphemeral(ic, fmt.Sprintf(":x: Failed to assign issue #%d", it.Number))
+			}
+			return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: Issue #%d assigned to the owner of the token for **%s**", it.Number, r.Name()))
+
+		} else if x, found := strings.CutPrefix(customID, idIssueClose); found {
+			it, r, err := b.loadIssueForAction(ic, x, userID)
+			if errors.Is(err, ErrNotFound) {
+				return b.respondEphemeral(ic, ":x: You have no access to this issue")
+			} else if err != nil {
+				return err
+			}
+			if time.Since(it.CreatedAt) > issueUndoTimeout {
+				return b.respondEphemeral(ic, fmt.Sprintf(
+					":x: Issues can only be closed within %s after creation", issueUndoTimeout,
+				))
+			}
+			if err := b.api.closeIssue(r, it.Number); err != nil {
+				slog.Warn("Failed to close issue", "url", it.URL, "error", err)
+				return b.respondEphemeral(ic, fmt.Sprintf(":x: Failed to close issue #%d", it.Number))
+			}
+			slog.Info("Issue closed", "repo", r.Name(), "number", it.Number)
+			return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: Issue #%d closed", it.Number))
+
+		} else if x, found := strings.CutPrefix(customID, idIssuePost); found {
+			it, r, err := b.loadIssueForAction(ic, x, userID)
+			if errors.Is(err, ErrNotFound) {
+				return b.respondEphemeral(ic, ":x: You have no access to this issue")
+			} else if err != nil {
 				return err
 			}
-			r, err := b.st.GetRepo(repoID)
+			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: fmt.Sprintf(
+						"**%s** created an issue on %s: %s\n%s", user.Username, r.Name(), it.Title, it.URL,
+					),
+					AllowedMentions: &discordgo.MessageAllowedMentions{},
+				},
+			})
+			return err
+
+		} else if x, found := strings.CutPrefix(customID, idIssueComment1); found {
+			issueID, err := strconv.Atoi(x)
 			if err != nil {
 				return err
 			}
-			err = b.st.DeleteRepo(repoID)
+			it, err := b.st.GetIssue(issueID)
 			if err != nil {
 				return err
 			}
 			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Type: discordgo.InteractionResponseModal,
 				Data: &discordgo.InteractionResponseData{
-					Flags: discordgo.MessageFlagsEphemeral | discordgo.MessageFlagsIsComponentsV2,
+					CustomID: fmt.Sprintf("%s%d", idIssueComment2, it.ID),
+					Title:    fmt.Sprintf("Add comment to #%d", it.Number),
 					Components: []discordgo.MessageComponent{
-						discordgo.TextDisplay{
-							Content: fmt.Sprintf(":white_check_mark: Repo removed: %s", r.Name()),
+						discordgo.ActionsRow{
+							Components: []discordgo.MessageComponent{
+								discordgo.TextInput{
+									CustomID: "comment",
+									Label:    "Comment",
+									Style:    discordgo.TextInputParagraph,
+									Required: true,
+								},
+							},
 						},
 					},
 				},
 			})
 			return err
 
-		} else if x, found := strings.CutPrefix(customID, idRepoTest); found {
-			repoID, err := strconv.Atoi(x)
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateIssue1); found {
+			s, err := b.sessions.Load(sessionID, userID)
 			if err != nil {
 				return err
 			}
-			r, err := b.st.GetRepo(repoID)
+			idx, err := strconv.Atoi(data.Values[0])
 			if err != nil {
 				return err
 			}
-			var s string
-			status, err := b.api.checkToken(r)
+			repos, err := b.listReposForSession(ic, userID, s)
 			if err != nil {
-				slog.Warn("Failed to check token", "error", err)
-				var m string
-				switch status {
-				case http.StatusUnauthorized:
-					m = "Invalid token"
-				case http.StatusNotFound:
-					m = "Repository not found"
-				default:
-					m = "Internal error"
+				return err
+			}
+			if !slices.ContainsFunc(repos, func(x *Repo) bool { return x.ID == idx }) {
+				return b.respondEphemeral(ic, ":x: Unknown repo: "+data.Values[0])
+			}
+			s.repoID = idx
+			if err := b.sessions.Store(sessionID, s); err != nil {
+				return err
+			}
+			var d *discordgo.InteractionResponseData
+			if s.hasPreview {
+				d, err = b.makeIssuePreviewData(sessionID, s)
+				if err != nil {
+					return err
 				}
-				s = fmt.Sprintf(":x: Test failed: %s\nERROR: %s", r.Name(), m)
 			} else {
-				s = fmt.Sprintf(":white_check_mark: Test succeeded: %s", r.Name())
+				d = makeIssueTypePickerData(sessionID, nil, "")
 			}
 			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Flags: discordgo.MessageFlagsEphemeral | discordgo.MessageFlagsIsComponentsV2,
-					Components: []discordgo.MessageComponent{
-						discordgo.TextDisplay{
-							Content: s,
-						},
-					},
-				},
+				Type: discordgo.InteractionResponseUpdateMessage,
+				Data: d,
 			})
 			return err
-		}
-		return fmt.Errorf("unhandled message component interaction: %s", customID)
-
-	case discordgo.InteractionModalSubmit:
-		data := ic.ModalSubmitData()
-		customID := data.CustomID
 
-		if sessionID, found := strings.CutPrefix(customID, idIssueCreateIssue3); found {
-			x, ok := b.sessions.Load(sessionID)
-			if !ok {
-				return fmt.Errorf("failed to load session")
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateIssue2); found {
+			s, err := b.sessions.Load(sessionID, userID)
+			if err != nil {
+				return err
 			}
-			title := data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-			description := data.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-
-			s := x.(createIssueData)
-			var source string
-			if s.guildID != "" {
-				messageURL := fmt.Sprintf("https://discord.com/channels/%s/%s/%s", s.guildID, s.channelID, s.messageID)
-				source = fmt.Sprintf("[Discord](%s)", messageURL)
-			} else {
-				source = "Discord"
+			idx, err := strconv.Atoi(data.Values[0])
+			if err != nil {
+				return err
 			}
-			body := fmt.Sprintf(
-				"> %s\n\n*Originally posted by **%s** on %s*",
-				s.messageContent,
-				s.authorName,
-				source,
-			)
-			if description != "" {
-				body += "\n\n" + description
+			s.issueType = issueType(idx)
+			if err := b.sessions.Store(sessionID, s); err != nil {
+				return err
 			}
-			r, err := b.st.GetRepo(s.repoID)
-			if err != nil {
+			if s.hasPreview {
+				d, err := b.makeIssuePreviewData(sessionID, s)
+				if err != nil {
+					return err
+				}
+				err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseUpdateMessage,
+					Data: d,
+				})
 				return err
 			}
-			var labels []string
-			switch s.issueType {
-			case bugReport:
-				labels = append(labels, "bug")
-			case featureRequest:
-				labels = append(labels, "enhancement")
+			d, err := b.makeIssueDetailsModalData(sessionID, s)
+			if err != nil {
+				return err
 			}
-
-			htmlURL, err := b.api.createIssue(r, createIssueParams{
-				body:   body,
-				labels: labels,
-				title:  title,
+			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseModal,
+				Data: d,
 			})
+			return err
+
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateEdit); found {
+			s, err := b.sessions.Load(sessionID, userID)
+			if err != nil {
+				return err
+			}
+			d, err := b.makeIssueDetailsModalData(sessionID, s)
 			if err != nil {
 				return err
 			}
-			slog.Info("Issue created", "repo", r.Name(), "title", title, "url", htmlURL)
 			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseModal,
+				Data: d,
+			})
+			return err
+
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateType); found {
+			if _, err := b.sessions.Load(sessionID, userID); err != nil {
+				return err
+			}
+			err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseUpdateMessage,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf(":white_check_mark: Issue created on %s\n%s", r.Name(), htmlURL),
-					Flags:   discordgo.MessageFlagsEphemeral,
-				},
+				Data: makeIssueTypePickerData(sessionID, nil, ""),
 			})
+			return err
+
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateSubmit); found {
+			// the session is taken first, so that clicking twice does not create the issue twice
+			s, err := b.sessions.Take(sessionID, userID)
 			if err != nil {
 				return err
 			}
-			b.sessions.Delete(sessionID)
-			return nil
+			created, err := func() (bool, error) {
+				r, err := b.st.GetRepo(s.repoID)
+				if err != nil {
+					return false, err
+				}
+				return b.createOrSubmitIssue(ic, userID, s, r, discordgo.InteractionResponseUpdateMessage)
+			}()
+			if !created {
+				// allows trying again
+				if err2 := b.sessions.Store(sessionID, s); err2 != nil {
+					slog.Error("Failed to restore session", "sessionID", sessionID, "error", err2)
+				}
+				return err
+			}
+			// the issue was created, so the session must not be used again even when responding failed
+			errs := []error{err}
+			if s.draftID != 0 {
+				if err := b.st.DeleteDraft(s.draftID); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if len(s.basket) > 0 {
+				if err := b.removeFromBasket(userID, s.basket); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
 
-		} else if userID, found := strings.CutPrefix(customID, idRepoAdd2); found {
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateDraft); found {
+			return b.saveDraft(ic, userID, sessionID)
+
+		} else if customID == idBasketCreate {
+			return b.createIssueFromBasket(ic, userID)
+
+		} else if customID == idBasketClear {
+			if err := b.clearBasket(userID); err != nil {
+				return err
+			}
 			err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+				Type: discordgo.InteractionResponseUpdateMessage,
 				Data: &discordgo.InteractionResponseData{
-					Flags: discordgo.MessageFlagsEphemeral,
+					Content:    ":wastebasket: Basket cleared",
+					Components: []discordgo.MessageComponent{},
 				},
 			})
+			return err
+
+		} else if x, found := strings.CutPrefix(customID, idDraftResume); found {
+			return b.resumeDraft(ic, userID, x, false)
+
+		} else if x, found := strings.CutPrefix(customID, idDraftEdit); found {
+			return b.resumeDraft(ic, userID, x, true)
+
+		} else if x, found := strings.CutPrefix(customID, idDraftDiscard); found {
+			return b.discardDraft(ic, userID, x)
+
+		} else if x, found := strings.CutPrefix(customID, idRepoDelete); found {
+			repoID, err := strconv.Atoi(x)
 			if err != nil {
 				return err
 			}
-			rawURL := data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-			owner, repo, vendor, err := parseRepoURL(rawURL)
-			if err != nil {
-				slog.Warn("Failed to parse URL", "url", rawURL, "error", err)
-				_, err2 := b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
-					Content: ":x: Failed to add repo: " + err.Error(),
-				})
-				if err2 != nil {
-					return err2
-				}
-				return nil
-			}
-			token := data.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
-			rTemp := &Repo{
-				Owner:  owner,
-				Repo:   repo,
-				Token:  token,
-				UserID: userID,
-				Vendor: vendor,
-			}
-			status, err := b.api.checkToken(rTemp)
-			if err != nil {
-				slog.Warn("Failed to verify repo", "error", err)
-				var m string
-				switch status {
-				case http.StatusUnauthorized:
-					m = "Invalid token"
-				case http.StatusNotFound:
-					m = "Repository not found"
-				default:
-					m = "Internal error"
-				}
-				_, err2 := b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
-					Content: fmt.Sprintf(":x: Failed to add repo: %s\n%s", rTemp.Name(), m),
-				})
-				if err2 != nil {
-					return err2
-				}
-				return nil
-			}
-			r, created, err := b.st.UpdateOrCreateRepo(UpdateOrCreateRepoParams{
-				UserID: userID,
-				Owner:  owner,
-				Repo:   repo,
-				Token:  token,
-				Vendor: vendor,
-			})
+			return b.removeRepo(ic, userID, repoID)
+
+		} else if x, found := strings.CutPrefix(customID, idRepoTest); found {
+			repoID, err := strconv.Atoi(x)
 			if err != nil {
 				return err
 			}
-			var action string
-			if created {
-				action = "added"
-			} else {
-				action = "updated"
-			}
-			_, err = b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
-				Content: fmt.Sprintf(":white_check_mark: Repo %s: %s", action, r.Name()),
-			})
-			if err != nil {
+			r, err := b.getManageableRepo(ic, userID, repoID)
+			if errors.Is(err, ErrNotFound) {
+				return b.respondEphemeral(ic, ":x: You are not permitted to manage this repo")
+			} else if err != nil {
 				return err
 			}
-			return nil
+			return b.testRepos(ic, []*Repo{r})
 		}
-		return fmt.Errorf("unhandled modal submit: %s", customID)
-	}
-
-	return fmt.Errorf("unexpected interaction type %d", ic.Type)
-}
+		return fmt.Errorf("unhandled message component interaction: %s", customID)
 
-func (b *Bot) newSessionID() string {
-	return strconv.Itoa(int(b.counter.Add(1)))
+	case discordgo.InteractionModalSubmit:
+		data := ic.ModalSubmitData()
+		customID := data.CustomID
+
+		if sessionID, found := strings.CutPrefix(customID, idIssueCreateIssue3); found {
+			s, err := b.sessions.Load(sessionID, userID)
+			if err != nil {
+				return err
+			}
+			s.title = data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+			s.description = data.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+			s.hasPreview = true
+			if err := b.sessions.Store(sessionID, s); err != nil {
+				return err
+			}
+			if s.draftID != 0 {
+				// the entered details are kept, even when the preview is not confirmed
+				d, err := b.st.GetDraft(s.draftID)
+				if err == nil && d.UserID == userID {
+					err = b.updateDraft(d, s)
+				}
+				if err != nil && !errors.Is(err, ErrNotFound) {
+					return err
+				}
+			}
+			d, err := b.makeIssuePreviewData(sessionID, s)
+			if err != nil {
+				return err
+			}
+			t := discordgo.InteractionResponseUpdateMessage
+			if ic.Message != nil && ic.Message.Flags&discordgo.MessageFlagsIsComponentsV2 != 0 {
+				t = discordgo.InteractionResponseChannelMessageWithSource // e.g. when editing from the list of drafts
+			}
+			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+				Type: t,
+				Data: d,
+			})
+			return err
+
+		} else if sessionID, found := strings.CutPrefix(customID, idIssueCreateSearch2); found {
+			s, err := b.sessions.Load(sessionID, userID)
+			if err != nil {
+				return err
+			}
+			s.repoSearch = strings.TrimSpace(data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)
+			s.repoPage = 0
+			if err := b.sessions.Store(sessionID, s); err != nil {
+				return err
+			}
+			d, err := b.makeRepoPickerData(ic, userID, sessionID)
+			if err != nil {
+				return err
+			}
+			err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseUpdateMessage,
+				Data: d,
+			})
+			return err
+
+		} else if x, found := strings.CutPrefix(customID, idIssueAddLabel2); found {
+			it, r, err := b.loadIssueForAction(ic, x, userID)
+			if errors.Is(err, ErrNotFound) {
+				return b.respondEphemeral(ic, ":x: You have no access to this issue")
+			} else if err != nil {
+				return err
+			}
+			raw := data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+			var labels []string
+			for l := range strings.SplitSeq(raw, ",") {
+				if l = strings.TrimSpace(l); l != "" {
+					labels = append(labels, l)
+				}
+			}
+			if len(labels) == 0 {
+				return b.respondEphemeral(ic, ":x: No labels given")
+			}
+			if err := b.api.addLabels(r, it.Number, labels); err != nil {
+				slog.Warn("Failed to add labels", "url", it.URL, "error", err)
+				return b.respondEphemeral(ic, fmt.Sprintf(":x: Failed to add labels to issue #%d", it.Number))
+			}
+			return b.respondEphemeral(ic, fmt.Sprintf(
+				":white_check_mark: Labels added to issue #%d: %s", it.Number, strings.Join(labels, ", "),
+			))
+
+		} else if x, found := strings.CutPrefix(customID, idIssueComment2); found {
+			issueID, err := strconv.Atoi(x)
+			if err != nil {
+				return err
+			}
+			it, err := b.st.GetIssue(issueID)
+			if err != nil {
+				return err
+			}
+			r, err := b.findRepoForIssue(ic, userID, it)
+			if errors.Is(err, ErrNotFound) {
+				return b.respondEphemeral(ic, ":x: You have no access to the repo of this issue")
+			} else if err != nil {
+				return err
+			}
+			comment := data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+			// marked, so that comment sync does not post it back into the thread
+			body := fmt.Sprintf("%s\n\n*Posted by **%s** on Discord*\n\n%s", comment, user.Username, syncedCommentMarker)
+			if err := b.api.createComment(r, it.Number, body); err != nil {
+				return err
+			}
+			slog.Info("Comment created", "repo", r.Name(), "number", it.Number)
+			return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: Comment added to %s", it.URL))
+
+		} else if customID == idRepoAdd2 {
+			return b.addRepo(ic, data, userID, "")
+
+		} else if after, found := strings.CutPrefix(customID, idRepoTemplate); found {
+			repoID, err := strconv.Atoi(after)
+			if err != nil {
+				return err
+			}
+			return b.saveTemplates(ic, userID, repoID, data)
+
+		} else if customID == idGuildRepoAdd2 {
+			ok, err := b.hasPermission(ic, permManageRepos, 0)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return b.respondEphemeral(ic, ":x: You are not permitted to add shared repos")
+			}
+			return b.addRepo(ic, data, userID, ic.GuildID)
+		}
+		return fmt.Errorf("unhandled modal submit: %s", customID)
+	}
+
+	return fmt.Errorf("unexpected interaction type %d", ic.Type)
+}
+
+// addRepo adds a new repo or updates an existing repo from a submitted modal.
+// The repo is shared with a guild when a guild ID is given.
+func (b *Bot) addRepo(ic *discordgo.InteractionCreate, data discordgo.ModalSubmitInteractionData, userID, guildID string) error {
+	err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	rawURL := data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+	owner, repo, vendor, err := parseRepoURL(rawURL)
+	if err != nil {
+		slog.Warn("Failed to parse URL", "url", rawURL, "error", err)
+		_, err2 := b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
+			Content: ":x: Failed to add repo: " + err.Error(),
+		})
+		if err2 != nil {
+			return err2
+		}
+		return nil
+	}
+	token := data.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+	rTemp := &Repo{
+		Owner:  owner,
+		Repo:   repo,
+		Token:  token,
+		UserID: userID,
+		Vendor: vendor,
+	}
+	status, err := b.api.checkToken(rTemp)
+	if err != nil {
+		slog.Warn("Failed to verify repo", "error", err)
+		var m string
+		switch status {
+		case http.StatusUnauthorized:
+			m = "Invalid token"
+		case http.StatusNotFound:
+			m = "Repository not found"
+		default:
+			m = "Internal error"
+		}
+		_, err2 := b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
+			Content: fmt.Sprintf(":x: Failed to add repo: %s\n%s", rTemp.Name(), m),
+		})
+		if err2 != nil {
+			return err2
+		}
+		return nil
+	}
+	r, created, err := b.st.UpdateOrCreateRepo(UpdateOrCreateRepoParams{
+		GuildID: guildID,
+		UserID:  userID,
+		Owner:   owner,
+		Repo:    repo,
+		Token:   token,
+		Vendor:  vendor,
+	})
+	if err != nil {
+		return err
+	}
+	var action string
+	if created {
+		action = "added"
+	} else {
+		action = "updated"
+	}
+	_, err = b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
+		Content: fmt.Sprintf(":white_check_mark: Repo %s: %s", action, r.Name()),
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// createIssue creates a new issue on a repo and stores it.
+// It returns the stored issue, which is nil when storing failed, and the created issue.
+// Bodies exceeding the limit of the vendor are truncated.
+func (b *Bot) createIssue(r *Repo, userID string, s createIssueData, arg createIssueParams) (*Issue, *vendorIssue, error) {
+	arg.body = b.fitIssueBody(r, arg.title, arg.body)
+	number, htmlURL, err := b.api.createIssue(r, arg)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("Issue created", "repo", r.Name(), "title", arg.title, "url", htmlURL)
+	x := &vendorIssue{
+		labels: arg.labels,
+		number: number,
+		state:  issueStateOpen,
+		title:  arg.title,
+		url:    htmlURL,
+	}
+	it, err := b.st.CreateIssue(CreateIssueParams{
+		AuthorID:         s.authorID,
+		ChannelID:        s.channelID,
+		GuildID:          s.guildID,
+		MessageID:        s.messageID,
+		MessageTimestamp: s.messageTimestamp,
+		Number:           number,
+		RepoID:           r.ID,
+		ThreadID:         s.threadID,
+		Title:            arg.title,
+		URL:              htmlURL,
+		UserID:           userID,
+	})
+	if err != nil {
+		slog.Error("Failed to store issue", "url", htmlURL, "error", err)
+		return nil, x, nil
+	}
+	return it, x, nil
+}
+
+// showRepos responds with the list of repos of a user.
+func (b *Bot) showRepos(ic *discordgo.InteractionCreate, userID string) error {
+	err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	repos, err := b.st.ListReposForUser(userID)
+	if err != nil {
+		return err
+	}
+	components := []discordgo.MessageComponent{discordgo.TextDisplay{
+		Content: fmt.Sprintf("%d repos", len(repos)),
+	}}
+	for _, r := range repos {
+		components = append(components, makeRepoContainer(r))
+	}
+	components = append(components, discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				CustomID: idRepoAdd1,
+				Label:    "Add repository",
+			},
+		},
+	})
+	return b.sendComponentsPaged(ic, components)
+}
+
+// showGuildRepos responds with the list of repos shared with the current guild
+// and the channel for reviewing submitted issues.
+func (b *Bot) showGuildRepos(ic *discordgo.InteractionCreate) error {
+	err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	repos, err := b.st.ListReposForGuild(ic.GuildID)
+	if err != nil {
+		return err
+	}
+	g, err := b.st.GetGuild(ic.GuildID)
+	if err != nil {
+		return err
+	}
+	components := []discordgo.MessageComponent{discordgo.TextDisplay{
+		Content: fmt.Sprintf("%d shared repos", len(repos)),
+	}}
+	for _, r := range repos {
+		components = append(components, makeRepoContainer(r))
+	}
+	names := make(map[int]string)
+	for _, r := range repos {
+		names[r.ID] = r.Name()
+	}
+	if len(g.ChannelRepos) > 0 {
+		var lines []string
+		for channelID, repoID := range g.ChannelRepos {
+			name, ok := names[repoID]
+			if !ok {
+				name = "deleted repo"
+			}
+			lines = append(lines, fmt.Sprintf("- <#%s>: %s", channelID, name))
+		}
+		slices.Sort(lines)
+		components = append(components, discordgo.TextDisplay{
+			Content: "Default repos for channels:\n" + strings.Join(lines, "\n"),
+		})
+	}
+	if len(g.Forums) > 0 {
+		var lines []string
+		for channelID, f := range g.Forums {
+			name, ok := names[f.RepoID]
+			if !ok {
+				name = "deleted repo"
+			}
+			line := fmt.Sprintf("- <#%s>: %s", channelID, name)
+			if f.RequireApproval {
+				line += " (with review)"
+			}
+			lines = append(lines, line)
+		}
+		slices.Sort(lines)
+		components = append(components, discordgo.TextDisplay{
+			Content: "Watched forums:\n" + strings.Join(lines, "\n"),
+		})
+	}
+	if g.ReactionEmoji != "" {
+		components = append(components, discordgo.TextDisplay{
+			Content: fmt.Sprintf("Reaction for creating issues: %s", displayEmoji(g.ReactionEmoji)),
+		})
+	}
+	var channels []discordgo.SelectMenuDefaultValue
+	if g.ReviewChannelID != "" {
+		channels = append(channels, discordgo.SelectMenuDefaultValue{
+			ID:   g.ReviewChannelID,
+			Type: discordgo.SelectMenuDefaultValueChannel,
+		})
+	}
+	minValues := 0
+	components = append(components,
+		discordgo.TextDisplay{
+			Content: "Issues submitted for review are posted in this channel:",
+		},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					ChannelTypes:  []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+					CustomID:      idGuildReviewChannel,
+					DefaultValues: channels,
+					MaxValues:     1,
+					MenuType:      discordgo.ChannelSelectMenu,
+					MinValues:     &minValues,
+					Placeholder:   "No review channel",
+				},
+			},
+		},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					CustomID: idGuildRepoAdd1,
+					Label:    "Add shared repository",
+				},
+			},
+		},
+	)
+	return b.sendComponentsPaged(ic, components)
+}
+
+// showHistory responds with the issues a user has recently created,
+// optionally filtered by a repo, and their current state.
+func (b *Bot) showHistory(ic *discordgo.InteractionCreate, userID string, repoID int) error {
+	err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	issues, err := b.st.ListIssuesForUser(userID)
+	if err != nil {
+		return err
+	}
+	if repoID != 0 {
+		issues = slices.DeleteFunc(issues, func(x *Issue) bool {
+			return x.RepoID != repoID
+		})
+	}
+	if len(issues) > maxHistoryIssues {
+		issues = issues[:maxHistoryIssues]
+	}
+
+	// fetch current state of issues from vendors
+	repoNames := make([]string, len(issues))
+	vendorIssues := make([]*vendorIssue, len(issues))
+	var wg sync.WaitGroup
+	for i, it := range issues {
+		// issues of the user were created with these repos, which includes shared repos
+		r, err := b.st.GetRepo(it.RepoID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		repoNames[i] = r.Name()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			x, err := b.api.getIssue(r, it.Number)
+			if err != nil {
+				slog.Warn("Failed to fetch issue", "url", it.URL, "error", err)
+				return
+			}
+			vendorIssues[i] = x
+		}()
+	}
+	wg.Wait()
+
+	components := []discordgo.MessageComponent{discordgo.TextDisplay{
+		Content: fmt.Sprintf("%d issues", len(issues)),
+	}}
+	for i, it := range issues {
+		title := it.Title
+		state := ":grey_question: Unknown"
+		if x := vendorIssues[i]; x != nil {
+			title = x.title
+			state = displayIssueState(x.state)
+		}
+		name := cmp.Or(repoNames[i], "deleted repo")
+		components = append(components, discordgo.Container{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextDisplay{
+					Content: fmt.Sprintf(
+						"**[%s](%s)**\n%s#%d · %s · <t:%d:R>",
+						title, it.URL, name, it.Number, state, it.CreatedAt.Unix(),
+					),
+				},
+			},
+		})
+	}
+	return b.sendComponentsPaged(ic, components)
+}
+
+// showIssue responds with the details of an issue,
+// which is fetched from the vendor with the token of a matching repo.
+func (b *Bot) showIssue(ic *discordgo.InteractionCreate, userID, ref string) error {
+	err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	respondWithError := func(m string) error {
+		_, err := b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
+			Content: ":x: " + m,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		})
+		return err
+	}
+	owner, repo, vendor, number, err := parseIssueRef(ref)
+	if err != nil {
+		return respondWithError(fmt.Sprintf("Invalid issue: %s", err))
+	}
+	repos, err := b.listReposForInteraction(ic, userID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(repos, func(r *Repo) bool {
+		return strings.EqualFold(r.Owner, owner) && strings.EqualFold(r.Repo, repo) && (vendor == "" || r.Vendor == vendor)
+	})
+	if i == -1 {
+		return respondWithError(fmt.Sprintf("You have no repo for %s/%s", owner, repo))
+	}
+	r := repos[i]
+	x, err := b.api.getIssue(r, number)
+	if err != nil {
+		slog.Warn("Failed to fetch issue", "repo", r.Name(), "number", number, "error", err)
+		return respondWithError(fmt.Sprintf("Failed to fetch issue #%d from %s", number, r.Name()))
+	}
+	_, err = b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
+		Flags:      discordgo.MessageFlagsEphemeral | discordgo.MessageFlagsIsComponentsV2,
+		Components: []discordgo.MessageComponent{makeIssueCard(r, x)},
+	})
+	return err
+}
+
+// showPermissions responds with the permission rules of the current guild.
+func (b *Bot) showPermissions(ic *discordgo.InteractionCreate) error {
+	err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	g, err := b.st.GetGuild(ic.GuildID)
+	if err != nil {
+		return err
+	}
+	components := []discordgo.MessageComponent{discordgo.TextDisplay{
+		Content: fmt.Sprintf(
+			"%d rules\n-# Server managers always have all permissions. "+
+				"Add rules with the options of this command.",
+			len(g.Rules),
+		),
+	}}
+	for _, x := range g.Rules {
+		var target string
+		if x.RoleID != "" {
+			target = fmt.Sprintf("<@&%s>", x.RoleID)
+		} else {
+			target = fmt.Sprintf("<@%s>", x.UserID)
+		}
+		scope := "all shared repos"
+		if x.RepoID != 0 {
+			r, err := b.st.GetRepo(x.RepoID)
+			if errors.Is(err, ErrNotFound) {
+				scope = "deleted repo"
+			} else if err != nil {
+				return err
+			} else {
+				scope = r.Name()
+			}
+		}
+		components = append(components, discordgo.Section{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextDisplay{
+					Content: fmt.Sprintf("**%s**: %s on %s", x.Permission.Display(), target, scope),
+				},
+			},
+			Accessory: discordgo.Button{
+				CustomID: fmt.Sprintf("%s%d", idPermissionRemove, x.ID),
+				Label:    "Remove",
+				Style:    discordgo.DangerButton,
+			},
+		})
+	}
+	return b.sendComponentsPaged(ic, components)
+}
+
+// createIssueFromCommand creates an issue from the options of a sub command.
+func (b *Bot) createIssueFromCommand(ic *discordgo.InteractionCreate, user *discordgo.User, sub *discordgo.ApplicationCommandInteractionDataOption) error {
+	o := sub.GetOption(optRepo)
+	if o == nil {
+		return fmt.Errorf("missing option for %s: %s", sub.Name, optRepo)
+	}
+	repoID, err := strconv.Atoi(o.StringValue())
+	if err != nil {
+		return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+	}
+	repos, err := b.listReposForInteraction(ic, user.ID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(repos, func(x *Repo) bool {
+		return x.ID == repoID
+	})
+	if i == -1 {
+		return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+	}
+	r := repos[i]
+	var title, description string
+	if o := sub.GetOption(optTitle); o != nil {
+		title = o.StringValue()
+	}
+	if o := sub.GetOption(optDescription); o != nil {
+		description = o.StringValue()
+	}
+	it := neutralIssue
+	if o := sub.GetOption(optType); o != nil {
+		it = issueType(o.IntValue())
+	}
+	var attachments []Attachment
+	if o := sub.GetOption(optAttachment); o != nil {
+		attachments = append(attachments, newAttachment(ic.ApplicationCommandData().Resolved.Attachments[o.Value.(string)]))
+	}
+	s := createIssueData{
+		attachments: attachments,
+		authorID:    user.ID,
+		authorName:  user.Username,
+		channelID:   ic.ChannelID,
+		description: description,
+		guildID:     ic.GuildID,
+		issueType:   it,
+		repoID:      r.ID,
+		title:       b.issueTemplates(r).prefixTitle(title),
+		userID:      user.ID,
+	}
+	_, err = b.createOrSubmitIssue(ic, user.ID, s, r, discordgo.InteractionResponseChannelMessageWithSource)
+	return err
+}
+
+// createOrSubmitIssue creates an issue on a repo from the data of a session and responds with it.
+// Issues for shared repos are submitted for review instead, when the user is only permitted to submit them.
+//
+// The response is deferred, because fetching attachments and calling the vendor's API can take longer than Discord permits.
+// The message of the interaction is replaced by the response when t is [discordgo.InteractionResponseUpdateMessage].
+//
+// Reports whether the issue was created or submitted, which can also be the case when responding failed.
+func (b *Bot) createOrSubmitIssue(ic *discordgo.InteractionCreate, userID string, s createIssueData, r *Repo, t discordgo.InteractionResponseType) (bool, error) {
+	if !r.IsShared() && r.UserID != userID {
+		return false, b.respondEphemeral(ic, ":x: You are not permitted to create issues with this repo")
+	}
+	var review *Guild // guild for reviewing the issue, when it is submitted instead of created
+	if r.IsShared() {
+		if r.GuildID != s.guildID {
+			return false, b.respondEphemeral(ic, ":x: You are not permitted to create issues with this repo")
+		}
+		canCreate, err := b.sessionHasPermission(ic, s, permCreateIssues, r.ID)
+		if err != nil {
+			return false, err
+		}
+		if !canCreate {
+			canSubmit, err := b.sessionHasPermission(ic, s, permSubmitIssues, r.ID)
+			if err != nil {
+				return false, err
+			}
+			if !canSubmit {
+				return false, b.respondEphemeral(ic, ":x: You are not permitted to create issues with this repo")
+			}
+			review, err = b.st.GetGuild(s.guildID)
+			if err != nil {
+				return false, err
+			}
+			if review.ReviewChannelID == "" {
+				return false, b.respondEphemeral(ic, ":x: This server has no channel for reviewing submitted issues")
+			}
+		}
+	}
+	dt := discordgo.InteractionResponseDeferredChannelMessageWithSource
+	if t == discordgo.InteractionResponseUpdateMessage {
+		dt = discordgo.InteractionResponseDeferredMessageUpdate
+	}
+	err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: dt,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	params, err := func() (*discordgo.WebhookParams, error) {
+		arg, err := makeCreateIssueParams(b.issueTemplates(r), b.withAttachments(s, true), s.description)
+		if err != nil {
+			return nil, err
+		}
+		if review != nil {
+			return b.submitIssue(review, userID, s, r, arg)
+		}
+		it, vi, err := b.createIssue(r, userID, s, arg)
+		if err != nil {
+			return nil, err
+		}
+		components := []discordgo.MessageComponent{
+			discordgo.TextDisplay{
+				Content: fmt.Sprintf(":white_check_mark: Issue created on %s", r.Name()),
+			},
+			makeIssueCard(r, vi),
+		}
+		if it != nil {
+			components = append(components, makeIssueActionsRow(it))
+		}
+		return &discordgo.WebhookParams{
+			Flags:      discordgo.MessageFlagsIsComponentsV2,
+			Components: components,
+		}, nil
+	}()
+	if err != nil {
+		// the deferred response must be completed, or the user would be left waiting
+		params = &discordgo.WebhookParams{Content: ":x: Failed to create issue on " + r.Name()}
+	}
+	created := err == nil
+	params.Flags |= discordgo.MessageFlagsEphemeral
+	if t == discordgo.InteractionResponseUpdateMessage && created {
+		// the message is kept on errors, so that the user can try again
+		if err2 := b.ds.InteractionResponseDelete(ic.Interaction); err2 != nil {
+			err = errors.Join(err, err2)
+		}
+	}
+	if _, err2 := b.ds.FollowupMessageCreate(ic.Interaction, false, params); err2 != nil {
+		err = errors.Join(err, err2)
+	}
+	return created, err
+}
+
+// submitIssue submits an issue for review in the review channel of a guild
+// and returns the response for the user.
+func (b *Bot) submitIssue(g *Guild, userID string, s createIssueData, r *Repo, arg createIssueParams) (*discordgo.WebhookParams, error) {
+	_, err := b.postSubmission(g, r, CreateSubmissionParams{
+		AuthorID:         s.authorID,
+		Body:             arg.body,
+		ChannelID:        s.channelID,
+		GuildID:          s.guildID,
+		Labels:           arg.labels,
+		MessageID:        s.messageID,
+		MessageTimestamp: s.messageTimestamp,
+		RepoID:           r.ID,
+		ThreadID:         s.threadID,
+		Title:            arg.title,
+		UserID:           userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &discordgo.WebhookParams{
+		Content: fmt.Sprintf(":inbox_tray: Your issue has been submitted for review: %s", arg.title),
+	}, nil
+}
+
+// postSubmission stores a new submission and posts it in the review channel of a guild.
+func (b *Bot) postSubmission(g *Guild, r *Repo, arg CreateSubmissionParams) (*Submission, error) {
+	const maxBodyLength = 1500
+	if g.ReviewChannelID == "" {
+		return nil, fmt.Errorf("guild %s has no review channel: %w", g.ID, ErrInvalidArguments)
+	}
+	x, err := b.st.CreateSubmission(arg)
+	if err != nil {
+		return nil, err
+	}
+	body := x.Body
+	if runes := []rune(body); len(runes) > maxBodyLength {
+		body = string(runes[:maxBodyLength]) + "…"
+	}
+	_, err = b.ds.ChannelMessageSendComplex(g.ReviewChannelID, &discordgo.MessageSend{
+		Flags:           discordgo.MessageFlagsIsComponentsV2,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+		Components: []discordgo.MessageComponent{
+			discordgo.Container{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextDisplay{
+						Content: fmt.Sprintf(
+							"-# Issue submitted for review by <@%s> for %s\n### %s", x.UserID, r.Name(), x.Title,
+						),
+					},
+					discordgo.TextDisplay{
+						Content: body,
+					},
+				},
+			},
+			makeSubmissionActionsRow(x),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// reviewSubmission approves or rejects a submitted issue.
+// Approving creates the issue on the submission's repo.
+func (b *Bot) reviewSubmission(ic *discordgo.InteractionCreate, submissionID string, approve bool) error {
+	id, err := strconv.Atoi(submissionID)
+	if err != nil {
+		return err
+	}
+	sub, err := b.st.GetSubmission(id)
+	if errors.Is(err, ErrNotFound) {
+		return b.respondEphemeral(ic, ":x: This submission has already been reviewed")
+	} else if err != nil {
+		return err
+	}
+	ok, err := b.hasPermission(ic, permCreateIssues, sub.RepoID)
+	if err != nil {
+		return err
+	}
+	if !ok || sub.GuildID != ic.GuildID {
+		return b.respondEphemeral(ic, ":x: You are not permitted to review issues for this repo")
+	}
+	var r *Repo
+	if approve {
+		r, err = b.st.GetRepo(sub.RepoID)
+		if errors.Is(err, ErrNotFound) {
+			return b.respondEphemeral(ic, ":x: The repo of this submission no longer exists")
+		} else if err != nil {
+			return err
+		}
+	}
+	// claim the submission first, so that concurrent reviews can not create the issue twice
+	sub, err = b.st.ClaimSubmission(sub.ID)
+	if errors.Is(err, ErrNotFound) {
+		return b.respondEphemeral(ic, ":x: This submission has already been reviewed")
+	} else if err != nil {
+		return err
+	}
+	// creating the issue can take longer than Discord permits for responding
+	err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		if err2 := b.st.RestoreSubmission(sub); err2 != nil {
+			slog.Error("Failed to restore submission", "id", sub.ID, "error", err2)
+		}
+		return err
+	}
+	reviewer := ic.Member.User.ID
+	var components []discordgo.MessageComponent
+	if approve {
+		s := createIssueData{
+			authorID:         sub.AuthorID,
+			channelID:        sub.ChannelID,
+			guildID:          sub.GuildID,
+			messageID:        sub.MessageID,
+			messageTimestamp: sub.MessageTimestamp,
+			repoID:           sub.RepoID,
+			threadID:         sub.ThreadID,
+		}
+		_, x, err := b.createIssue(r, sub.UserID, s, createIssueParams{
+			body:   sub.Body,
+			labels: sub.Labels,
+			title:  sub.Title,
+		})
+		if err != nil {
+			if err2 := b.st.RestoreSubmission(sub); err2 != nil {
+				slog.Error("Failed to restore submission", "id", sub.ID, "error", err2)
+			}
+			_, err2 := b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
+				Content: ":x: Failed to create issue on " + r.Name(),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			})
+			return errors.Join(err, err2)
+		}
+		components = []discordgo.MessageComponent{
+			discordgo.TextDisplay{
+				Content: fmt.Sprintf(
+					":white_check_mark: Submitted by <@%s> and approved by <@%s>", sub.UserID, reviewer,
+				),
+			},
+			makeIssueCard(r, x),
+		}
+		if sub.ThreadID != "" {
+			if err := b.postIssueToThread(sub.ThreadID, r, x); err != nil {
+				slog.Warn("Failed to post issue to thread", "threadID", sub.ThreadID, "error", err)
+			}
+		}
+	} else {
+		components = []discordgo.MessageComponent{
+			discordgo.TextDisplay{
+				Content: fmt.Sprintf(
+					":no_entry: Submitted by <@%s> and rejected by <@%s>\n### %s", sub.UserID, reviewer, sub.Title,
+				),
+			},
+		}
+	}
+	_, err = b.ds.InteractionResponseEdit(ic.Interaction, &discordgo.WebhookEdit{
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+		Components:      &components,
+	})
+	return err
+}
+
+// respondEphemeral responds to an interaction with a message only visible to the user.
+func (b *Bot) respondEphemeral(ic *discordgo.InteractionCreate, content string) error {
+	return b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// sendComponentsPaged sends components as follow-up messages to a deferred interaction.
+// Components are split across several messages when necessary.
+func (b *Bot) sendComponentsPaged(ic *discordgo.InteractionCreate, components []discordgo.MessageComponent) error {
+	for chunk := range slices.Chunk(components, maxComponentsPerPage) {
+		_, err := b.ds.FollowupMessageCreate(ic.Interaction, false, &discordgo.WebhookParams{
+			Flags:      discordgo.MessageFlagsEphemeral | discordgo.MessageFlagsIsComponentsV2,
+			Components: chunk,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// makeRepoChoices returns the autocomplete choices for repos matching a search string.
+func makeRepoChoices(repos []*Repo, search string) []*discordgo.ApplicationCommandOptionChoice {
+	const maxChoices = 25
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	for _, r := range filterRepos(repos, search) {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  displayRepoName(r),
+			Value: strconv.Itoa(r.ID),
+		})
+		if len(choices) == maxChoices {
+			break
+		}
+	}
+	return choices
+}
+
+// filterRepos returns the repos which names contain search ignoring case.
+func filterRepos(repos []*Repo, search string) []*Repo {
+	search = strings.ToLower(search)
+	var filtered []*Repo
+	for _, r := range repos {
+		if strings.Contains(strings.ToLower(r.Name()), search) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// sortRepos returns repos ordered for choosing one:
+// favorites first, then recently used repos with the most recent first and then all others.
+// The order of repos is kept otherwise.
+func sortRepos(repos []*Repo, favoriteIDs, recentIDs []int) []*Repo {
+	rank := func(r *Repo) int {
+		if slices.Contains(favoriteIDs, r.ID) {
+			return 0
+		}
+		if i := slices.Index(recentIDs, r.ID); i != -1 {
+			return i + 1
+		}
+		return len(recentIDs) + 1
+	}
+	sorted := slices.Clone(repos)
+	slices.SortStableFunc(sorted, func(a, b *Repo) int {
+		return cmp.Compare(rank(a), rank(b))
+	})
+	return sorted
+}
+
+// sortReposForUser returns repos ordered for a user to choose one from.
+// See also [sortRepos].
+func (b *Bot) sortReposForUser(userID string, repos []*Repo) ([]*Repo, *User, error) {
+	u, err := b.st.GetUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	issues, err := b.st.ListIssuesForUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var recentIDs []int
+	for _, it := range issues {
+		if !slices.Contains(recentIDs, it.RepoID) {
+			recentIDs = append(recentIDs, it.RepoID)
+		}
+	}
+	return sortRepos(repos, u.FavoriteRepoIDs, recentIDs), u, nil
+}
+
+// makeRepoContainer returns a component for showing and managing a repo.
+func makeRepoContainer(r *Repo) discordgo.Container {
+	return discordgo.Container{
+		Components: []discordgo.MessageComponent{
+			discordgo.TextDisplay{
+				Content: fmt.Sprintf("[%s](%s)", r.Name(), r.URL()),
+			},
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						CustomID: fmt.Sprintf("%s%d", idRepoDelete, r.ID),
+						Label:    "Remove",
+						Style:    discordgo.DangerButton,
+					},
+					discordgo.Button{
+						CustomID: fmt.Sprintf("%s%d", idRepoTest, r.ID),
+						Label:    "Test",
+					},
+				},
+			},
+		},
+	}
+}
+
+// makeRepoAddModalData returns the modal for adding a repo.
+func makeRepoAddModalData(customID string) *discordgo.InteractionResponseData {
+	return &discordgo.InteractionResponseData{
+		CustomID: customID,
+		Title:    "Add repo",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:    "url",
+						Label:       "Repository URL",
+						Placeholder: "https://github.com/{OWNER}/{REPO}",
+						Required:    true,
+						Style:       discordgo.TextInputShort,
+					},
+				},
+			},
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:    "token",
+						Label:       "Token",
+						Placeholder: "Token with permission to read & write issues",
+						Required:    true,
+						Style:       discordgo.TextInputShort,
+					},
+				},
+			},
+		},
+	}
+}
+
+// makeCreateIssueStartData returns the first response for creating an issue.
+// Step 1 is skipped when the channel or the user has a default repo available to the user.
+func (b *Bot) makeCreateIssueStartData(ic *discordgo.InteractionCreate, userID, sessionID string) (*discordgo.InteractionResponseData, error) {
+	s, err := b.sessions.Load(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := b.listReposForInteraction(ic, userID)
+	if err != nil {
+		return nil, err
+	}
+	r, err := b.findChannelRepo(ic, repos)
+	if err != nil {
+		return nil, err
+	}
+	reason := "default for this channel"
+	if r == nil {
+		u, err := b.st.GetUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		r = findRepoByOption(repos, strconv.Itoa(u.DefaultRepoID))
+		reason = "your default"
+	}
+	if r == nil {
+		return b.makeRepoPickerData(ic, userID, sessionID)
+	}
+	s.repoID = r.ID
+	if err := b.sessions.Store(sessionID, s); err != nil {
+		return nil, err
+	}
+	return makeIssueTypePickerData(sessionID, r, reason), nil
+}
+
+// makeIssueTypePickerData returns the response for choosing the issue type in step 2 of creating an issue.
+// When defaultRepo is not nil, the preselected repo is shown with the reason and a button to change it.
+func makeIssueTypePickerData(sessionID string, defaultRepo *Repo, reason string) *discordgo.InteractionResponseData {
+	options := []discordgo.SelectMenuOption{
+		{
+			Label: "Bug report",
+			Value: strconv.Itoa(int(bugReport)),
+		},
+		{
+			Label: "Feature request",
+			Value: strconv.Itoa(int(featureRequest)),
+		},
+		{
+			Label: "Other issue (no label)",
+			Value: strconv.Itoa(int(neutralIssue)),
+		},
+	}
+	d := &discordgo.InteractionResponseData{
+		Content: "Create issue [2 / 3]",
+		Flags:   discordgo.MessageFlagsEphemeral,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						CustomID:    idIssueCreateIssue2 + sessionID,
+						Options:     options,
+						Placeholder: "Choose issue type",
+					},
+				},
+			},
+		},
+	}
+	buttons := []discordgo.MessageComponent{makeSaveDraftButton(sessionID)}
+	if defaultRepo != nil {
+		d.Content += fmt.Sprintf("\nRepo: **%s** (%s)", defaultRepo.Name(), reason)
+		buttons = slices.Insert(buttons, 0, discordgo.MessageComponent(discordgo.Button{
+			CustomID: idIssueCreateChange + sessionID,
+			Label:    "Change repo",
+			Style:    discordgo.SecondaryButton,
+		}))
+	}
+	d.Components = append(d.Components, discordgo.ActionsRow{Components: buttons})
+	return d
+}
+
+// makeSaveDraftButton returns the button for saving the issue being created as draft.
+func makeSaveDraftButton(sessionID string) discordgo.Button {
+	return discordgo.Button{
+		CustomID: idIssueCreateDraft + sessionID,
+		Label:    "Save as draft",
+		Style:    discordgo.SecondaryButton,
+	}
+}
+
+// makeIssueDetailsModalData returns the modal for entering the details of an issue in step 3 of creating an issue.
+// Details which have not been entered yet are prefilled from the message and the templates of the repo.
+func (b *Bot) makeIssueDetailsModalData(sessionID string, s createIssueData) (*discordgo.InteractionResponseData, error) {
+	t := b.templates.Merge(defaultIssueTemplates)
+	if s.repoID != 0 {
+		r, err := b.st.GetRepo(s.repoID)
+		if err == nil {
+			t = b.issueTemplates(r)
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	s, err := prefillIssueDetails(t, s)
+	if err != nil {
+		return nil, err
+	}
+	d := &discordgo.InteractionResponseData{
+		CustomID: idIssueCreateIssue3 + sessionID,
+		Title:    "Create issue [3 / 3]",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:  "title",
+						Label:     "Title",
+						MaxLength: maxTitleLength,
+						Required:  true,
+						Style:     discordgo.TextInputShort,
+						Value:     s.title,
+					},
+				},
+			},
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID: "description",
+						Label:    "Description",
+						Style:    discordgo.TextInputParagraph,
+						Value:    s.description,
+					},
+				},
+			},
+		},
+	}
+	return d, nil
+}
+
+// makeIssuePreviewData returns the response for reviewing an issue before it is created.
+func (b *Bot) makeIssuePreviewData(sessionID string, s createIssueData) (*discordgo.InteractionResponseData, error) {
+	r, err := b.st.GetRepo(s.repoID)
+	if err != nil {
+		return nil, err
+	}
+	// attachments are only linked, since fetching them would take too long for responding
+	arg, err := makeCreateIssueParams(b.issueTemplates(r), b.withAttachments(s, false), s.description)
+	if err != nil {
+		return nil, err
+	}
+	d := &discordgo.InteractionResponseData{
+		Content: makeIssuePreview(r, arg),
+		Flags:   discordgo.MessageFlagsEphemeral,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						CustomID: idIssueCreateEdit + sessionID,
+						Label:    "Edit",
+						Style:    discordgo.SecondaryButton,
+					},
+					discordgo.Button{
+						CustomID: idIssueCreateChange + sessionID,
+						Label:    "Change repo",
+						Style:    discordgo.SecondaryButton,
+					},
+					discordgo.Button{
+						CustomID: idIssueCreateType + sessionID,
+						Label:    "Change type",
+						Style:    discordgo.SecondaryButton,
+					},
+					makeSaveDraftButton(sessionID),
+					discordgo.Button{
+						CustomID: idIssueCreateSubmit + sessionID,
+						Label:    "Submit",
+						Style:    discordgo.SuccessButton,
+					},
+				},
+			},
+		},
+	}
+	return d, nil
+}
+
+// makeIssuePreview returns the content of a message showing an issue as it will be created.
+// The body is shortened to fit into a Discord message.
+func makeIssuePreview(r *Repo, arg createIssueParams) string {
+	const maxLength = 2000
+	var labels []string
+	for _, l := range arg.labels {
+		labels = append(labels, "`"+l+"`")
+	}
+	if len(labels) == 0 {
+		labels = []string{"-"}
+	}
+	header := fmt.Sprintf(
+		"Create issue [Preview]\n**Repo:** %s\n**Labels:** %s\n**Title:** %s\n**Body:**\n",
+		r.Name(),
+		strings.Join(labels, ", "),
+		arg.title,
+	)
+	const shortened = "…\n-# The body has been shortened for this preview"
+	body := arg.body
+	if n := maxLength - utf8.RuneCountInString(header); utf8.RuneCountInString(body) > n {
+		body = string([]rune(body)[:n-utf8.RuneCountInString(shortened)]) + shortened
+	}
+	return header + body
+}
+
+// findChannelRepo returns the default repo for the channel of an interaction from repos.
+// Channels inherit the default repo of their parent channel and category.
+// Returns nil when there is no default repo or it is not in repos.
+func (b *Bot) findChannelRepo(ic *discordgo.InteractionCreate, repos []*Repo) (*Repo, error) {
+	const maxParents = 2 // threads have a parent channel, which can have a category
+	if !b.isGuildInstalled(ic) || ic.ChannelID == "" {
+		return nil, nil
+	}
+	g, err := b.st.GetGuild(ic.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.ChannelRepos) == 0 {
+		return nil, nil
+	}
+	channelIDs := []string{ic.ChannelID}
+	id := ic.ChannelID
+	for range maxParents {
+		c, err := b.channel(id)
+		if err != nil {
+			slog.Warn("Failed to fetch channel", "channelID", id, "error", err)
+			break
+		}
+		if c.ParentID == "" {
+			break
+		}
+		channelIDs = append(channelIDs, c.ParentID)
+		id = c.ParentID
+	}
+	repoID, ok := g.ChannelRepo(channelIDs...)
+	if !ok {
+		return nil, nil
+	}
+	for _, r := range repos {
+		if r.ID == repoID {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// makeRepoPickerData returns the response for choosing a repo in step 1 of creating an issue.
+func (b *Bot) makeRepoPickerData(ic *discordgo.InteractionCreate, userID, sessionID string) (*discordgo.InteractionResponseData, error) {
+	s, err := b.sessions.Load(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := b.listReposForSession(ic, userID, s)
+	if err != nil {
+		return nil, err
+	}
+	repos, u, err := b.sortReposForUser(userID, repos)
+	if err != nil {
+		return nil, err
+	}
+	return makeRepoSelectData(repos, u.FavoriteRepoIDs, sessionID, s), nil
+}
+
+// makeRepoSelectData returns the response for choosing one of repos in step 1 of creating an issue.
+// Repos are filtered by the search of the session and shown in pages.
+// The repo of the session is preselected.
+func makeRepoSelectData(repos []*Repo, favoriteIDs []int, sessionID string, s createIssueData) *discordgo.InteractionResponseData {
+	if len(repos) == 0 {
+		d := &discordgo.InteractionResponseData{
+			Content: ":exclamation: Please add a repo",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		}
+		return d
+	}
+	hasManyRepos := len(repos) > maxSelectOptions
+	lines := []string{"Create issue [1 / 3]"}
+	if s.repoSearch != "" {
+		repos = filterRepos(repos, s.repoSearch)
+		lines = append(lines, fmt.Sprintf("-# %d repos matching \"%s\"", len(repos), s.repoSearch))
+	}
+	pageCount := max(1, (len(repos)+maxSelectOptions-1)/maxSelectOptions)
+	page := min(max(0, s.repoPage), pageCount-1)
+	if pageCount > 1 {
+		lines = append(lines, fmt.Sprintf("-# Page %d of %d", page+1, pageCount))
+	}
+	var components []discordgo.MessageComponent
+	if len(repos) > 0 {
+		options := make([]discordgo.SelectMenuOption, 0)
+		for _, r := range repos[page*maxSelectOptions : min(len(repos), (page+1)*maxSelectOptions)] {
+			label := displayRepoName(r)
+			if slices.Contains(favoriteIDs, r.ID) {
+				label = "★ " + label
+			}
+			options = append(options, discordgo.SelectMenuOption{
+				Default: r.ID == s.repoID,
+				Label:   label,
+				Value:   strconv.Itoa(r.ID),
+			})
+		}
+		components = append(components, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					CustomID:    idIssueCreateIssue1 + sessionID,
+					Options:     options,
+					Placeholder: "Choose repo",
+				},
+			},
+		})
+	}
+	if hasManyRepos || s.repoSearch != "" {
+		components = append(components, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					CustomID: fmt.Sprintf("%s%s-%d", idIssueCreatePage, sessionID, page-1),
+					Disabled: page == 0,
+					Label:    "Previous",
+					Style:    discordgo.SecondaryButton,
+				},
+				discordgo.Button{
+					CustomID: fmt.Sprintf("%s%s-%d", idIssueCreatePage, sessionID, page+1),
+					Disabled: page >= pageCount-1,
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+				},
+				discordgo.Button{
+					CustomID: idIssueCreateSearch1 + sessionID,
+					Label:    "Search",
+				},
+			},
+		})
+	}
+	components = append(components, discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{makeSaveDraftButton(sessionID)},
+	})
+	d := &discordgo.InteractionResponseData{
+		Content:    strings.Join(lines, "\n"),
+		Flags:      discordgo.MessageFlagsEphemeral,
+		Components: components,
+	}
+	return d
+}
+
+// makeDuplicateIssuesData returns the response for a message
+// which has already been turned into issues.
+func (b *Bot) makeDuplicateIssuesData(ic *discordgo.InteractionCreate, userID, sessionID string, issues []*Issue) (*discordgo.InteractionResponseData, error) {
+	const maxIssues = 4 // one row is needed for the proceed button
+	var lines []string
+	var components []discordgo.MessageComponent
+	for i, it := range issues {
+		if i == maxIssues {
+			lines = append(lines, fmt.Sprintf("- and %d more", len(issues)-maxIssues))
+			break
+		}
+		var name string
+		r, err := b.st.GetRepo(it.RepoID)
+		if errors.Is(err, ErrNotFound) {
+			name = "deleted repo"
+		} else if err != nil {
+			return nil, err
+		} else {
+			name = r.Name()
+		}
+		lines = append(lines, fmt.Sprintf(
+			"- [%s#%d](%s) created by <@%s> <t:%d:R>", name, it.Number, it.URL, it.UserID, it.CreatedAt.Unix(),
+		))
+		_, err = b.findRepoForIssue(ic, userID, it)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		components = append(components, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label: fmt.Sprintf("Open #%d", it.Number),
+					Style: discordgo.LinkButton,
+					URL:   it.URL,
+				},
+				discordgo.Button{
+					CustomID: fmt.Sprintf("%s%d", idIssueComment1, it.ID),
+					Disabled: err != nil,
+					Label:    fmt.Sprintf("Add comment to #%d", it.Number),
+				},
+			},
+		})
+	}
+	components = append(components, discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				CustomID: idIssueCreateProceed + sessionID,
+				Label:    "Create new issue anyway",
+				Style:    discordgo.SecondaryButton,
+			},
+		},
+	})
+	d := &discordgo.InteractionResponseData{
+		Content:    ":warning: This message has already been turned into an issue:\n" + strings.Join(lines, "\n"),
+		Flags:      discordgo.MessageFlagsEphemeral,
+		Components: components,
+	}
+	return d, nil
+}
+
+// loadIssueForAction returns an issue created by a user and the repo for accessing it.
+// The issue is identified by its ID as string.
+// Returns [ErrNotFound] when the issue was not created by the user
+// or the user has no repo to access it.
+func (b *Bot) loadIssueForAction(ic *discordgo.InteractionCreate, issueID string, userID string) (*Issue, *Repo, error) {
+	id, err := strconv.Atoi(issueID)
+	if err != nil {
+		return nil, nil, err
+	}
+	it, err := b.st.GetIssue(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if it.UserID != userID {
+		return nil, nil, ErrNotFound
+	}
+	r, err := b.findRepoForIssue(ic, userID, it)
+	if err != nil {
+		return nil, nil, err
+	}
+	return it, r, nil
+}
+
+// listReposForInteraction returns the repos a user can create issues with in the context of an interaction.
+// These are the user's own repos and the repos shared with the current guild, when the user is permitted.
+func (b *Bot) listReposForInteraction(ic *discordgo.InteractionCreate, userID string) ([]*Repo, error) {
+	var member *discordgo.Member
+	if b.isGuildInstalled(ic) {
+		member = ic.Member
+	}
+	return b.listReposForMember(userID, ic.GuildID, member)
+}
+
+// listReposForSession returns the repos a user can create issues with in a session.
+// Sessions started outside of an interaction use the guild member stored in the session.
+func (b *Bot) listReposForSession(ic *discordgo.InteractionCreate, userID string, s createIssueData) ([]*Repo, error) {
+	if s.member != nil {
+		return b.listReposForMember(userID, s.guildID, s.member)
+	}
+	return b.listReposForInteraction(ic, userID)
+}
+
+// listReposForMember returns the user's own repos and the repos shared with a guild, when the member is permitted.
+// Shared repos are only included when member is not nil.
+func (b *Bot) listReposForMember(userID, guildID string, member *discordgo.Member) ([]*Repo, error) {
+	repos, err := b.st.ListReposForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return repos, nil
+	}
+	shared, err := b.listPermittedGuildRepos(guildID, member)
+	if err != nil {
+		return nil, err
+	}
+	repos = append(shared, repos...)
+	return repos, nil
+}
+
+// listPermittedGuildRepos returns the repos shared with a guild, which a member can create or submit issues with.
+func (b *Bot) listPermittedGuildRepos(guildID string, member *discordgo.Member) ([]*Repo, error) {
+	shared, err := b.st.ListReposForGuild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	var permitted []*Repo
+	for _, r := range shared {
+		canCreate, err := b.memberHasPermission(guildID, member, permCreateIssues, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		canSubmit, err := b.memberHasPermission(guildID, member, permSubmitIssues, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		if canCreate || canSubmit {
+			permitted = append(permitted, r)
+		}
+	}
+	return permitted, nil
+}
+
+// hasPermission reports whether the user of an interaction has a permission for a repo shared with the current guild.
+// Repo ID 0 checks for a permission which applies to all shared repos.
+// Server managers have all permissions.
+func (b *Bot) hasPermission(ic *discordgo.InteractionCreate, p Permission, repoID int) (bool, error) {
+	if !b.isGuildInstalled(ic) {
+		return false, nil
+	}
+	return b.memberHasPermission(ic.GuildID, ic.Member, p, repoID)
+}
+
+// sessionHasPermission reports whether the user of a session has a permission for a shared repo.
+// Sessions started outside of an interaction use the guild member stored in the session.
+func (b *Bot) sessionHasPermission(ic *discordgo.InteractionCreate, s createIssueData, p Permission, repoID int) (bool, error) {
+	if s.member != nil {
+		return b.memberHasPermission(s.guildID, s.member, p, repoID)
+	}
+	return b.hasPermission(ic, p, repoID)
+}
+
+// memberHasPermission reports whether a guild member has a permission for a repo shared with a guild.
+// The permissions of the member must be computed for the guild.
+func (b *Bot) memberHasPermission(guildID string, m *discordgo.Member, p Permission, repoID int) (bool, error) {
+	if m == nil || m.User == nil {
+		return false, nil
+	}
+	if isGuildManager(m) {
+		return true, nil
+	}
+	g, err := b.st.GetGuild(guildID)
+	if err != nil {
+		return false, err
+	}
+	return g.IsPermitted(p, repoID, m.User.ID, m.Roles), nil
+}
+
+// listManagedGuildRepos returns the repos shared with the current guild, which the user of an interaction can manage.
+func (b *Bot) listManagedGuildRepos(ic *discordgo.InteractionCreate) ([]*Repo, error) {
+	if !b.isGuildInstalled(ic) {
+		return nil, nil
+	}
+	repos, err := b.st.ListReposForGuild(ic.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	var managed []*Repo
+	for _, r := range repos {
+		ok, err := b.hasPermission(ic, permManageRepos, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			managed = append(managed, r)
+		}
+	}
+	return managed, nil
+}
+
+// isGuildInstalled reports whether the app is installed in the guild of an interaction.
+func (b *Bot) isGuildInstalled(ic *discordgo.InteractionCreate) bool {
+	if !b.guildInstall || ic.GuildID == "" {
+		return false
+	}
+	return ic.AuthorizingIntegrationOwners[discordgo.ApplicationIntegrationGuildInstall] == ic.GuildID
+}
+
+// isGuildAdmin reports whether the member of an interaction can manage the current guild.
+func isGuildAdmin(ic *discordgo.InteractionCreate) bool {
+	if ic.Member == nil {
+		return false
+	}
+	return isGuildManager(ic.Member)
+}
+
+// isGuildManager reports whether a member can manage its guild.
+func isGuildManager(m *discordgo.Member) bool {
+	return m.Permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageGuild) != 0
+}
+
+// findRepoForIssue returns a repo available to a user, which can be used to access an issue.
+// Returns [ErrNotFound] when the user has no such repo
+// or is not permitted to create issues with it.
+func (b *Bot) findRepoForIssue(ic *discordgo.InteractionCreate, userID string, it *Issue) (*Repo, error) {
+	r, err := b.st.GetRepo(it.RepoID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	repos, err := b.listReposForInteraction(ic, userID)
+	if err != nil {
+		return nil, err
+	}
+	if slices.ContainsFunc(repos, func(x *Repo) bool {
+		return x.ID == r.ID
+	}) {
+		if r.IsShared() {
+			ok, err := b.hasPermission(ic, permCreateIssues, r.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, ErrNotFound
+			}
+		}
+		return r, nil
+	}
+	for _, r2 := range repos {
+		if r2.Name() == r.Name() {
+			return r2, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// newSessionID returns a new random session ID, which can not be guessed by other users.
+func (b *Bot) newSessionID() string {
+	return rand.Text()
+}
+
+// findFocusedOption returns the focused option of an autocomplete interaction
+// or nil if there is none.
+func findFocusedOption(options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, o := range options {
+		if o.Focused {
+			return o
+		}
+		if x := findFocusedOption(o.Options); x != nil {
+			return x
+		}
+	}
+	return nil
+}
+
+// makeIssueCard returns a component which shows the details of an issue.
+func makeIssueCard(r *Repo, x *vendorIssue) discordgo.Container {
+	const (
+		colorOpen   = 0x238636
+		colorClosed = 0x8250df
+	)
+	orNone := func(s []string) string {
+		if len(s) == 0 {
+			return "-"
+		}
+		return strings.Join(s, ", ")
+	}
+	var labels []string
+	for _, l := range x.labels {
+		labels = append(labels, "`"+l+"`")
+	}
+	color := colorOpen
+	if x.state == issueStateClosed {
+		color = colorClosed
+	}
+	c := discordgo.Container{
+		AccentColor: &color,
+		Components: []discordgo.MessageComponent{
+			discordgo.TextDisplay{
+				Content: fmt.Sprintf("### [%s](%s)\n-# %s#%d", x.title, x.url, r.Name(), x.number),
+			},
+			discordgo.TextDisplay{
+				Content: fmt.Sprintf(
+					"**State:** %s\n**Labels:** %s\n**Assignees:** %s\n**Milestone:** %s\n**Comments:** %d",
+					displayIssueState(x.state),
+					orNone(labels),
+					orNone(x.assignees),
+					cmp.Or(x.milestone, "-"),
+					x.comments,
+				),
+			},
+		},
+	}
+	return c
+}
+
+// makeIssueActionsRow returns the buttons for acting on a newly created issue.
+func makeIssueActionsRow(it *Issue) discordgo.ActionsRow {
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				CustomID: fmt.Sprintf("%s%d", idIssueAddLabel1, it.ID),
+				Label:    "Add label",
+				Style:    discordgo.SecondaryButton,
+			},
+			discordgo.Button{
+				CustomID: fmt.Sprintf("%s%d", idIssueAssign, it.ID),
+				Label:    "Assign to token owner",
+				Style:    discordgo.SecondaryButton,
+			},
+			discordgo.Button{
+				CustomID: fmt.Sprintf("%s%d", idIssueClose, it.ID),
+				Label:    "Close (undo)",
+				Style:    discordgo.DangerButton,
+			},
+			discordgo.Button{
+				CustomID: fmt.Sprintf("%s%d", idIssuePost, it.ID),
+				Label:    "Post link in channel",
+				Style:    discordgo.PrimaryButton,
+			},
+		},
+	}
+}
+
+// displayRepoName returns the name of a repo for showing in lists.
+func displayRepoName(r *Repo) string {
+	if r.IsShared() {
+		return r.Name() + " (shared)"
+	}
+	return r.Name()
+}
+
+// makeSubmissionActionsRow returns the buttons for reviewing a submitted issue.
+func makeSubmissionActionsRow(x *Submission) discordgo.ActionsRow {
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				CustomID: fmt.Sprintf("%s%d", idSubmissionApprove, x.ID),
+				Label:    "Approve",
+				Style:    discordgo.SuccessButton,
+			},
+			discordgo.Button{
+				CustomID: fmt.Sprintf("%s%d", idSubmissionReject, x.ID),
+				Label:    "Reject",
+				Style:    discordgo.DangerButton,
+			},
+		},
+	}
+}
+
+func displayIssueState(state string) string {
+	switch state {
+	case issueStateOpen:
+		return ":green_circle: Open"
+	case issueStateClosed:
+		return ":purple_circle: Closed"
+	}
+	return ":grey_question: Unknown"
+}
+
+// parseIssueRef parses a reference to an issue and returns its components.
+// Supported are issue URLs and short references like "owner/repo#123",
+// optionally prefixed by the vendor's host.
+// The vendor is empty when it can not be derived from the reference.
+func parseIssueRef(s string) (string, string, Vendor, int, error) {
+	s = strings.TrimSpace(s)
+	var vendor Vendor
+	var path string
+	var number string
+	if u, err := url.ParseRequestURI(s); err == nil && u.Host != "" {
+		switch u.Host {
+		case "github.com":
+			vendor = gitHub
+		case "gitlab.com":
+			vendor = gitLab
+		default:
+			return "", "", "", 0, fmt.Errorf("host must be github.com or gitlab.com: %w", ErrInvalidURL)
+		}
+		p := strings.TrimPrefix(u.Path, "/")
+		var found bool
+		path, number, found = strings.Cut(p, "/issues/")
+		if !found {
+			return "", "", "", 0, fmt.Errorf("not an issue URL: %w", ErrInvalidURL)
+		}
+		path = strings.TrimSuffix(path, "/-")
+	} else {
+		var found bool
+		path, number, found = strings.Cut(s, "#")
+		if !found {
+			return "", "", "", 0, fmt.Errorf("issue number missing: %w", ErrInvalidURL)
+		}
+		for _, v := range []Vendor{gitHub, gitLab} {
+			if x, found := strings.CutPrefix(path, v.Host()+"/"); found {
+				path = x
+				vendor = v
+				break
+			}
+		}
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", 0, fmt.Errorf("repo must have exactly two parts: %w", ErrInvalidURL)
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(number, "/"))
+	if err != nil || n <= 0 {
+		return "", "", "", 0, fmt.Errorf("invalid issue number: %w", ErrInvalidURL)
+	}
+	return parts[0], parts[1], vendor, n, nil
 }
 
 func parseRepoURL(s string) (string, string, Vendor, error) {
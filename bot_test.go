@@ -1,9 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
 )
 
 func TestParseURL(t *testing.T) {
@@ -35,3 +41,294 @@ func TestParseURL(t *testing.T) {
 		})
 	}
 }
+
+func TestFindFocusedOption(t *testing.T) {
+	t.Run("can find focused option in sub command", func(t *testing.T) {
+		options := []*discordgo.ApplicationCommandInteractionDataOption{
+			{
+				Name: "sub",
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{Name: "alpha"},
+					{Name: "bravo", Focused: true},
+				},
+			},
+		}
+		got := findFocusedOption(options)
+		if assert.NotNil(t, got) {
+			assert.Equal(t, "bravo", got.Name)
+		}
+	})
+	t.Run("should return nil when nothing is focused", func(t *testing.T) {
+		options := []*discordgo.ApplicationCommandInteractionDataOption{
+			{Name: "alpha"},
+		}
+		got := findFocusedOption(options)
+		assert.Nil(t, got)
+	})
+}
+
+func TestParseIssueRef(t *testing.T) {
+	cases := []struct {
+		name    string
+		ref     string
+		owner   string
+		repo    string
+		vendor  Vendor
+		number  int
+		isValid bool
+	}{
+		{"github URL", "https://github.com/owner/repo/issues/12", "owner", "repo", gitHub, 12, true},
+		{"gitlab URL", "https://gitlab.com/owner/repo/-/issues/12", "owner", "repo", gitLab, 12, true},
+		{"short reference", "owner/repo#12", "owner", "repo", "", 12, true},
+		{"short reference with host", "gitlab.com/owner/repo#12", "owner", "repo", gitLab, 12, true},
+		{"invalid host", "https://bitbucket.com/owner/repo/issues/12", "", "", "", 0, false},
+		{"not an issue URL", "https://github.com/owner/repo", "", "", "", 0, false},
+		{"number missing", "owner/repo", "", "", "", 0, false},
+		{"invalid number", "owner/repo#abc", "", "", "", 0, false},
+		{"repo missing", "owner#12", "", "", "", 0, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			owner, repo, vendor, number, err := parseIssueRef(tc.ref)
+			if tc.isValid {
+				if assert.NoError(t, err) {
+					assert.Equal(t, tc.owner, owner)
+					assert.Equal(t, tc.repo, repo)
+					assert.Equal(t, tc.vendor, vendor)
+					assert.Equal(t, tc.number, number)
+				}
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(p, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %s", err)
+	}
+	defer db.Close()
+	st := NewStorage(db)
+	if err = st.Init(); err != nil {
+		t.Fatal(err)
+	}
+	g := &Guild{ID: "guild1"}
+	g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role1"})
+	g.AddRule(PermissionRule{Permission: permSubmitIssues, RepoID: 7, UserID: "user2"})
+	if err := st.UpdateGuild(g); err != nil {
+		t.Fatal(err)
+	}
+	makeInteraction := func(guildID string, member *discordgo.Member) *discordgo.InteractionCreate {
+		return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			GuildID: guildID,
+			Member:  member,
+			AuthorizingIntegrationOwners: map[discordgo.ApplicationIntegrationType]string{
+				discordgo.ApplicationIntegrationGuildInstall: "guild1",
+			},
+		}}
+	}
+	makeMember := func(userID string, roles ...string) *discordgo.Member {
+		return &discordgo.Member{User: &discordgo.User{ID: userID}, Roles: roles}
+	}
+	manager := makeMember("user3")
+	manager.Permissions = discordgo.PermissionManageGuild
+	cases := []struct {
+		name         string
+		guildInstall bool
+		guildID      string
+		member       *discordgo.Member
+		permission   Permission
+		repoID       int
+		want         bool
+	}{
+		{"member with role for all repos", true, "guild1", makeMember("user1", "role1"), permCreateIssues, 7, true},
+		{"member without permitted role", true, "guild1", makeMember("user1", "role2"), permCreateIssues, 7, false},
+		{"member without permission", true, "guild1", makeMember("user1", "role1"), permManageRepos, 7, false},
+		{"user with permission for repo", true, "guild1", makeMember("user2"), permSubmitIssues, 7, true},
+		{"user with permission for other repo", true, "guild1", makeMember("user2"), permSubmitIssues, 8, false},
+		{"server manager", true, "guild1", manager, permManageRepos, 7, true},
+		{"guild install disabled", false, "guild1", makeMember("user1", "role1"), permCreateIssues, 7, false},
+		{"app not installed in guild", true, "guild2", makeMember("user1", "role1"), permCreateIssues, 7, false},
+		{"no member", true, "guild1", nil, permCreateIssues, 7, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &Bot{guildInstall: tc.guildInstall, st: st}
+			got, err := b.hasPermission(makeInteraction(tc.guildID, tc.member), tc.permission, tc.repoID)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSortRepos(t *testing.T) {
+	repos := []*Repo{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}
+	got := sortRepos(repos, []int{4}, []int{5, 2, 4})
+	var ids []int
+	for _, r := range got {
+		ids = append(ids, r.ID)
+	}
+	assert.Equal(t, []int{4, 5, 2, 1, 3}, ids)
+}
+
+func TestMakeRepoSelectData(t *testing.T) {
+	var repos []*Repo
+	for i := range 30 {
+		repos = append(repos, &Repo{ID: i + 1, Owner: "owner", Repo: fmt.Sprintf("repo%d", i+1), Vendor: gitHub})
+	}
+	options := func(d *discordgo.InteractionResponseData) []discordgo.SelectMenuOption {
+		return d.Components[0].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu).Options
+	}
+	t.Run("should show first page with paging buttons", func(t *testing.T) {
+		d := makeRepoSelectData(repos, []int{1}, "1", createIssueData{})
+		assert.Len(t, options(d), maxSelectOptions)
+		assert.Equal(t, "★ github.com/owner/repo1", options(d)[0].Label)
+		assert.Len(t, d.Components, 3)
+		buttons := d.Components[1].(discordgo.ActionsRow).Components
+		assert.True(t, buttons[0].(discordgo.Button).Disabled)
+		assert.False(t, buttons[1].(discordgo.Button).Disabled)
+		assert.Contains(t, d.Content, "Page 1 of 2")
+	})
+	t.Run("should show last page", func(t *testing.T) {
+		d := makeRepoSelectData(repos, nil, "1", createIssueData{repoPage: 5})
+		assert.Len(t, options(d), 5)
+		buttons := d.Components[1].(discordgo.ActionsRow).Components
+		assert.False(t, buttons[0].(discordgo.Button).Disabled)
+		assert.True(t, buttons[1].(discordgo.Button).Disabled)
+	})
+	t.Run("should filter by search", func(t *testing.T) {
+		d := makeRepoSelectData(repos, nil, "1", createIssueData{repoSearch: "REPO2"})
+		assert.Len(t, options(d), 11) // repo2 and repo20 to repo29
+		assert.Contains(t, d.Content, "matching")
+	})
+	t.Run("should not show paging for few repos", func(t *testing.T) {
+		d := makeRepoSelectData(repos[:3], nil, "1", createIssueData{})
+		assert.Len(t, d.Components, 2)
+	})
+	t.Run("should offer saving as draft", func(t *testing.T) {
+		d := makeRepoSelectData(repos[:3], nil, "1", createIssueData{})
+		button := d.Components[1].(discordgo.ActionsRow).Components[0].(discordgo.Button)
+		assert.Equal(t, idIssueCreateDraft+"1", button.CustomID)
+	})
+}
+
+func TestDiffCommands(t *testing.T) {
+	makeCmd := func(id, name, description string) *discordgo.ApplicationCommand {
+		return &discordgo.ApplicationCommand{
+			ID:          id,
+			Name:        name,
+			Description: description,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name: "type",
+					Type: discordgo.ApplicationCommandOptionInteger,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Bug", Value: 1},
+					},
+				},
+			},
+		}
+	}
+	current := []*discordgo.ApplicationCommand{
+		makeCmd("1", "alpha", "unchanged"),
+		makeCmd("2", "bravo", "old"),
+		makeCmd("3", "charlie", "obsolete"),
+	}
+	current[0].Type = discordgo.ChatApplicationCommand
+	current[0].Version = "42"
+	current[0].Options[0].Choices[0].Value = float64(1) // as decoded from JSON
+	desired := []*discordgo.ApplicationCommand{
+		makeCmd("", "alpha", "unchanged"),
+		makeCmd("", "bravo", "new"),
+		makeCmd("", "delta", "added"),
+	}
+	create, update, remove := diffCommands(current, desired)
+	if assert.Len(t, create, 1) {
+		assert.Equal(t, "delta", create[0].Name)
+	}
+	if assert.Len(t, update, 1) {
+		assert.Equal(t, "bravo", update[0].Name)
+		assert.Equal(t, "2", update[0].ID)
+		assert.Equal(t, "new", update[0].Description)
+	}
+	if assert.Len(t, remove, 1) {
+		assert.Equal(t, "charlie", remove[0].Name)
+	}
+}
+
+func TestCommandsEqual(t *testing.T) {
+	t.Run("should ignore order of integration types", func(t *testing.T) {
+		a := &discordgo.ApplicationCommand{
+			Name: "alpha",
+			IntegrationTypes: &[]discordgo.ApplicationIntegrationType{
+				discordgo.ApplicationIntegrationUserInstall, discordgo.ApplicationIntegrationGuildInstall,
+			},
+		}
+		b := &discordgo.ApplicationCommand{
+			Name: "alpha",
+			IntegrationTypes: &[]discordgo.ApplicationIntegrationType{
+				discordgo.ApplicationIntegrationGuildInstall, discordgo.ApplicationIntegrationUserInstall,
+			},
+		}
+		assert.True(t, commandsEqual(a, b))
+	})
+	t.Run("should detect changed sub command options", func(t *testing.T) {
+		makeCmd := func(required bool) *discordgo.ApplicationCommand {
+			return &discordgo.ApplicationCommand{
+				Name: "alpha",
+				Options: []*discordgo.ApplicationCommandOption{{
+					Name: "sub",
+					Type: discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{{
+						Name:     "repo",
+						Type:     discordgo.ApplicationCommandOptionString,
+						Required: required,
+					}},
+				}},
+			}
+		}
+		assert.False(t, commandsEqual(makeCmd(true), makeCmd(false)))
+		assert.True(t, commandsEqual(makeCmd(true), makeCmd(true)))
+	})
+}
+
+func TestMakeIssuePreview(t *testing.T) {
+	r := &Repo{Owner: "owner", Repo: "repo", Vendor: gitHub}
+	t.Run("should show issue as it will be created", func(t *testing.T) {
+		arg := createIssueParams{title: "title", body: "> quote\n\ndescription", labels: []string{"bug"}}
+		got := makeIssuePreview(r, arg)
+		assert.Equal(
+			t,
+			"Create issue [Preview]\n**Repo:** github.com/owner/repo\n**Labels:** `bug`\n**Title:** title\n**Body:**\n> quote\n\ndescription",
+			got,
+		)
+	})
+	t.Run("should shorten long body", func(t *testing.T) {
+		arg := createIssueParams{title: "title", body: strings.Repeat("x", 3000)}
+		got := makeIssuePreview(r, arg)
+		assert.Equal(t, 2000, utf8.RuneCountInString(got))
+		assert.Contains(t, got, "**Labels:** -")
+		assert.True(t, strings.HasSuffix(got, "shortened for this preview"))
+	})
+}
+
+func TestMakeIssueTypePickerData(t *testing.T) {
+	t.Run("should offer saving as draft", func(t *testing.T) {
+		d := makeIssueTypePickerData("1", nil, "")
+		buttons := d.Components[1].(discordgo.ActionsRow).Components
+		assert.Len(t, buttons, 1)
+		assert.Equal(t, idIssueCreateDraft+"1", buttons[0].(discordgo.Button).CustomID)
+	})
+	t.Run("should offer changing the default repo", func(t *testing.T) {
+		r := &Repo{Owner: "owner", Repo: "repo", Vendor: gitHub}
+		d := makeIssueTypePickerData("1", r, "your default")
+		assert.Contains(t, d.Content, "github.com/owner/repo")
+		buttons := d.Components[1].(discordgo.ActionsRow).Components
+		assert.Equal(t, idIssueCreateChange+"1", buttons[0].(discordgo.Button).CustomID)
+		assert.Equal(t, idIssueCreateDraft+"1", buttons[1].(discordgo.Button).CustomID)
+	})
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// saveDraft saves the contents of a session as draft of the user.
+// Drafts resumed from a session are updated.
+func (b *Bot) saveDraft(ic *discordgo.InteractionCreate, userID, sessionID string) error {
+	s, err := b.sessions.Load(sessionID, userID)
+	if err != nil {
+		return err
+	}
+	var d *Draft
+	if s.draftID != 0 {
+		d, err = b.st.GetDraft(s.draftID)
+		if errors.Is(err, ErrNotFound) {
+			d = nil
+		} else if err != nil {
+			return err
+		} else if d.UserID != userID {
+			return b.respondEphemeral(ic, ":x: You have no access to this draft")
+		}
+	}
+	if d != nil {
+		if err := b.updateDraft(d, s); err != nil {
+			return err
+		}
+	} else {
+		drafts, err := b.st.ListDraftsForUser(userID)
+		if err != nil {
+			return err
+		}
+		if len(drafts) >= maxDraftsPerUser {
+			return b.respondEphemeral(ic, ":x: You have reached the upper limit of drafts. Please discard some with `/issuebot drafts`")
+		}
+		s.member = nil // see updateDraft
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if _, err := b.st.CreateDraft(userID, s.repoID, s.title, data); err != nil {
+			return err
+		}
+	}
+	if err := b.sessions.Delete(sessionID); err != nil {
+		return err
+	}
+	err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf(":floppy_disk: Draft saved: %s\nContinue any time with `/issuebot drafts`", cmp.Or(s.title, "Untitled")),
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	return err
+}
+
+// updateDraft updates a draft with the contents of a session.
+// The guild member of the session is not kept, since its roles would become outdated.
+// Permissions are checked with the interaction instead, after the draft is resumed.
+func (b *Bot) updateDraft(d *Draft, s createIssueData) error {
+	s.member = nil
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	d.Data = data
+	d.RepoID = s.repoID
+	d.Title = s.title
+	return b.st.UpdateDraft(d)
+}
+
+// showDrafts responds with the drafts of a user.
+func (b *Bot) showDrafts(ic *discordgo.InteractionCreate, userID string) error {
+	err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	drafts, err := b.st.ListDraftsForUser(userID)
+	if err != nil {
+		return err
+	}
+	components := []discordgo.MessageComponent{discordgo.TextDisplay{
+		Content: fmt.Sprintf("%d drafts", len(drafts)),
+	}}
+	for _, d := range drafts {
+		repoName := "No repo chosen"
+		if r, err := b.st.GetRepo(d.RepoID); err == nil {
+			repoName = r.Name()
+		}
+		components = append(components, discordgo.Container{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextDisplay{
+					Content: fmt.Sprintf(
+						"**%s**\n-# %s · saved <t:%d:R>", cmp.Or(d.Title, "Untitled"), repoName, d.UpdatedAt.Unix(),
+					),
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							CustomID: fmt.Sprintf("%s%d", idDraftResume, d.ID),
+							Label:    "Resume",
+						},
+						discordgo.Button{
+							CustomID: fmt.Sprintf("%s%d", idDraftEdit, d.ID),
+							Label:    "Edit",
+							Style:    discordgo.SecondaryButton,
+						},
+						discordgo.Button{
+							CustomID: fmt.Sprintf("%s%d", idDraftDiscard, d.ID),
+							Label:    "Discard",
+							Style:    discordgo.DangerButton,
+						},
+					},
+				},
+			},
+		})
+	}
+	return b.sendComponentsPaged(ic, components)
+}
+
+// resumeDraft starts a new session from a draft of the user.
+// It responds with the preview or with the modal for editing the details when edit is true.
+// Drafts saved before the details were entered continue with choosing the issue type.
+// When the draft has no repo or its repo no longer exists, the user is asked to choose one.
+func (b *Bot) resumeDraft(ic *discordgo.InteractionCreate, userID, draftID string, edit bool) error {
+	d, err := b.loadDraft(draftID, userID)
+	if errors.Is(err, ErrNotFound) {
+		return b.respondEphemeral(ic, ":x: Draft not found")
+	} else if err != nil {
+		return err
+	}
+	var s createIssueData
+	if err := json.Unmarshal(d.Data, &s); err != nil {
+		return err
+	}
+	s.draftID = d.ID
+	s.member = nil // for drafts saved with outdated roles of the member
+	s.userID = userID
+	sessionID := b.newSessionID()
+	if err := b.sessions.Store(sessionID, s); err != nil {
+		return err
+	}
+	r, err := b.draftRepo(s)
+	if err != nil {
+		return err
+	}
+	if edit && r != nil {
+		d, err := b.makeIssueDetailsModalData(sessionID, s)
+		if err != nil {
+			return err
+		}
+		err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: d,
+		})
+		return err
+	}
+	data, err := b.makeResumedDraftData(ic, userID, sessionID, s, r)
+	if err != nil {
+		return err
+	}
+	err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	})
+	return err
+}
+
+// draftRepo returns the repo of a draft.
+// Returns nil when the draft has no repo, e.g. when it was saved from the repo picker, or the repo no longer exists.
+func (b *Bot) draftRepo(s createIssueData) (*Repo, error) {
+	if s.repoID == 0 {
+		return nil, nil
+	}
+	r, err := b.st.GetRepo(s.repoID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// makeResumedDraftData returns the response for continuing with a draft resumed in a session.
+// r is the repo of the draft or nil, when a repo needs to be chosen.
+func (b *Bot) makeResumedDraftData(ic *discordgo.InteractionCreate, userID, sessionID string, s createIssueData, r *Repo) (*discordgo.InteractionResponseData, error) {
+	if r == nil {
+		return b.makeRepoPickerData(ic, userID, sessionID)
+	}
+	if !s.hasPreview {
+		return makeIssueTypePickerData(sessionID, r, "from draft"), nil
+	}
+	return b.makeIssuePreviewData(sessionID, s)
+}
+
+// discardDraft deletes a draft of the user.
+func (b *Bot) discardDraft(ic *discordgo.InteractionCreate, userID, draftID string) error {
+	d, err := b.loadDraft(draftID, userID)
+	if errors.Is(err, ErrNotFound) {
+		return b.respondEphemeral(ic, ":x: Draft not found")
+	} else if err != nil {
+		return err
+	}
+	if err := b.st.DeleteDraft(d.ID); err != nil {
+		return err
+	}
+	return b.respondEphemeral(ic, fmt.Sprintf(":wastebasket: Draft discarded: %s", cmp.Or(d.Title, "Untitled")))
+}
+
+// loadDraft returns a draft of the user.
+// Returns [ErrNotFound] when the draft does not exist or belongs to another user.
+func (b *Bot) loadDraft(draftID, userID string) (*Draft, error) {
+	id, err := strconv.Atoi(draftID)
+	if err != nil {
+		return nil, err
+	}
+	d, err := b.st.GetDraft(id)
+	if err != nil {
+		return nil, err
+	}
+	if d.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return d, nil
+}
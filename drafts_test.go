@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestUpdateDraft(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(p, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %s", err)
+	}
+	defer db.Close()
+	st := NewStorage(db)
+	if err = st.Init(); err != nil {
+		t.Fatal(err)
+	}
+	b := &Bot{st: st}
+	t.Run("should not keep the guild member of the session", func(t *testing.T) {
+		d, err := st.CreateDraft("user1", 0, "", []byte(`{}`))
+		require.NoError(t, err)
+		s := createIssueData{member: &discordgo.Member{Roles: []string{"role1"}}, title: "title", userID: "user1"}
+		require.NoError(t, b.updateDraft(d, s))
+		d, err = st.GetDraft(d.ID)
+		require.NoError(t, err)
+		var got createIssueData
+		require.NoError(t, json.Unmarshal(d.Data, &got))
+		assert.Nil(t, got.member)
+		assert.Equal(t, "title", got.title)
+	})
+}
+
+func TestMakeResumedDraftData(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(p, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %s", err)
+	}
+	defer db.Close()
+	st := NewStorage(db)
+	if err = st.Init(); err != nil {
+		t.Fatal(err)
+	}
+	b := &Bot{st: st, sessions: newSessionStore(st)}
+	r := createRepo(t, st, UpdateOrCreateRepoParams{UserID: "user1"})
+	ic := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{}}
+	t.Run("should show repo picker for draft saved from the repo picker", func(t *testing.T) {
+		s := createIssueData{userID: "user1"}
+		require.NoError(t, b.sessions.Store("1", s))
+		r, err := b.draftRepo(s)
+		require.NoError(t, err)
+		assert.Nil(t, r)
+		got, err := b.makeResumedDraftData(ic, "user1", "1", s, r)
+		require.NoError(t, err)
+		assert.Equal(t, "Create issue [1 / 3]", got.Content)
+	})
+	t.Run("should show repo picker for draft with deleted repo", func(t *testing.T) {
+		r, err := b.draftRepo(createIssueData{repoID: 4711, userID: "user1"})
+		require.NoError(t, err)
+		assert.Nil(t, r)
+	})
+	t.Run("should show issue type picker for draft without details", func(t *testing.T) {
+		s := createIssueData{repoID: r.ID, userID: "user1"}
+		require.NoError(t, b.sessions.Store("2", s))
+		r2, err := b.draftRepo(s)
+		require.NoError(t, err)
+		assert.Equal(t, r.ID, r2.ID)
+		got, err := b.makeResumedDraftData(ic, "user1", "2", s, r2)
+		require.NoError(t, err)
+		assert.Equal(t, makeIssueTypePickerData("2", r2, "from draft"), got)
+	})
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// handleThreadCreate turns new posts in watched forum channels into issues
+// or submits them for review.
+func (b *Bot) handleThreadCreate(tc *discordgo.ThreadCreate) error {
+	if !b.guildInstall || !tc.NewlyCreated || tc.GuildID == "" || tc.ParentID == "" {
+		return nil
+	}
+	if b.ds.State.User != nil && tc.OwnerID == b.ds.State.User.ID {
+		return nil
+	}
+	g, err := b.st.GetGuild(tc.GuildID)
+	if err != nil {
+		return err
+	}
+	f, ok := g.Forums[tc.ParentID]
+	if !ok {
+		return nil
+	}
+	r, err := b.st.GetRepo(f.RepoID)
+	if errors.Is(err, ErrNotFound) || (err == nil && r.GuildID != tc.GuildID) {
+		slog.Warn("Repo for forum not found", "forumID", tc.ParentID, "repoID", f.RepoID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	forum, err := b.channel(tc.ParentID)
+	if err != nil {
+		return err
+	}
+	m, err := b.fetchStarterMessage(tc.ID)
+	if err != nil {
+		return err
+	}
+	s := createIssueData{
+		attachments:      makeAttachments(m),
+		authorID:         m.Author.ID,
+		authorName:       m.Author.Username,
+		channelID:        tc.ID,
+		guildID:          tc.GuildID,
+		messageContent:   b.makeMessageContent(tc.GuildID, m, nil),
+		messageID:        m.ID,
+		messageTimestamp: m.Timestamp,
+		repoID:           r.ID,
+		threadID:         tc.ID,
+		title:            b.issueTemplates(r).prefixTitle(tc.Name),
+	}
+	arg, err := makeCreateIssueParams(b.issueTemplates(r), b.withAttachments(s, true), "")
+	if err != nil {
+		return err
+	}
+	arg.labels = makeForumLabels(forum.AvailableTags, tc.AppliedTags)
+	if f.RequireApproval {
+		if g.ReviewChannelID == "" {
+			slog.Warn("Can not submit forum post without review channel", "guildID", g.ID, "threadID", tc.ID)
+			return nil
+		}
+		_, err := b.postSubmission(g, r, CreateSubmissionParams{
+			AuthorID:         s.authorID,
+			Body:             arg.body,
+			ChannelID:        s.channelID,
+			GuildID:          s.guildID,
+			Labels:           arg.labels,
+			MessageID:        s.messageID,
+			MessageTimestamp: s.messageTimestamp,
+			RepoID:           r.ID,
+			ThreadID:         s.threadID,
+			Title:            arg.title,
+			UserID:           tc.OwnerID,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = b.ds.ChannelMessageSend(
+			tc.ID, ":inbox_tray: This post has been submitted for review and will be turned into an issue once approved.",
+		)
+		return err
+	}
+	_, x, err := b.createIssue(r, tc.OwnerID, s, arg)
+	if err != nil {
+		return err
+	}
+	return b.postIssueToThread(tc.ID, r, x)
+}
+
+// fetchStarterMessage returns the first message of a forum post.
+// The message can be created shortly after the thread, so fetching it is retried.
+func (b *Bot) fetchStarterMessage(threadID string) (*discordgo.Message, error) {
+	const maxAttempts = 3
+	var err error
+	for i := range maxAttempts {
+		if i > 0 {
+			time.Sleep(time.Second)
+		}
+		var m *discordgo.Message
+		m, err = b.ds.ChannelMessage(threadID, threadID)
+		if err == nil {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("fetch starter message for thread %s: %w", threadID, err)
+}
+
+// channel returns a Discord channel from the state cache or the API.
+func (b *Bot) channel(channelID string) (*discordgo.Channel, error) {
+	c, err := b.ds.State.Channel(channelID)
+	if err == nil {
+		return c, nil
+	}
+	return b.ds.Channel(channelID)
+}
+
+// postIssueToThread posts a link to a newly created issue into a Discord thread.
+func (b *Bot) postIssueToThread(threadID string, r *Repo, x *vendorIssue) error {
+	_, err := b.ds.ChannelMessageSendComplex(threadID, &discordgo.MessageSend{
+		Flags: discordgo.MessageFlagsIsComponentsV2,
+		Components: []discordgo.MessageComponent{
+			discordgo.TextDisplay{
+				Content: fmt.Sprintf(":white_check_mark: This post has been turned into an issue on %s", r.Name()),
+			},
+			makeIssueCard(r, x),
+		},
+	})
+	return err
+}
+
+// setForum sets or removes the configuration for watching a forum channel
+// from the options of a sub command.
+func (b *Bot) setForum(ic *discordgo.InteractionCreate, sub *discordgo.ApplicationCommandInteractionDataOption) error {
+	if !b.isGuildInstalled(ic) {
+		return b.respondEphemeral(ic, ":x: Forums require issuebot to be installed on this server")
+	}
+	o := sub.GetOption(optForum)
+	if o == nil {
+		return fmt.Errorf("missing option for %s: %s", sub.Name, optForum)
+	}
+	channelID := o.Value.(string)
+	var f Forum
+	var r *Repo
+	if o := sub.GetOption(optRepo); o != nil {
+		x, err := strconv.Atoi(o.StringValue())
+		if err != nil {
+			return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+		}
+		r, err = b.st.GetRepo(x)
+		if errors.Is(err, ErrNotFound) || (err == nil && r.GuildID != ic.GuildID) {
+			return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+		} else if err != nil {
+			return err
+		}
+		f.RepoID = r.ID
+	}
+	if o := sub.GetOption(optApproval); o != nil {
+		f.RequireApproval = o.BoolValue()
+	}
+	g, err := b.st.GetGuild(ic.GuildID)
+	if err != nil {
+		return err
+	}
+	current, found := g.Forums[channelID]
+	if f.RepoID == 0 && !found {
+		return b.respondEphemeral(ic, fmt.Sprintf(":x: <#%s> is not watched", channelID))
+	}
+	for _, id := range []int{current.RepoID, f.RepoID} {
+		if id == 0 {
+			continue
+		}
+		ok, err := b.hasPermission(ic, permManageRepos, id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return b.respondEphemeral(ic, ":x: You are not permitted to manage this repo")
+		}
+	}
+	if f.RequireApproval && g.ReviewChannelID == "" {
+		return b.respondEphemeral(ic, ":x: Please set a review channel with `/issuebot server` first")
+	}
+	g.SetForum(channelID, f)
+	if err := b.st.UpdateGuild(g); err != nil {
+		return err
+	}
+	if r == nil {
+		return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: Stopped watching <#%s>", channelID))
+	}
+	var mode string
+	if f.RequireApproval {
+		mode = "submitted for review"
+	} else {
+		mode = "turned into issues"
+	}
+	return b.respondEphemeral(ic, fmt.Sprintf(
+		":white_check_mark: New posts in <#%s> will be %s on %s", channelID, mode, r.Name(),
+	))
+}
+
+// makeForumLabels returns the names of the tags applied to a forum post.
+func makeForumLabels(available []discordgo.ForumTag, applied []string) []string {
+	names := make(map[string]string)
+	for _, t := range available {
+		names[t.ID] = t.Name
+	}
+	var labels []string
+	for _, id := range applied {
+		if n, ok := names[id]; ok {
+			labels = append(labels, n)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeForumLabels(t *testing.T) {
+	available := []discordgo.ForumTag{
+		{ID: "1", Name: "bug"},
+		{ID: "2", Name: "enhancement"},
+		{ID: "3", Name: "question"},
+	}
+	cases := []struct {
+		name    string
+		applied []string
+		want    []string
+	}{
+		{"no tags", nil, nil},
+		{"some tags in applied order", []string{"3", "1"}, []string{"question", "bug"}},
+		{"unknown tag", []string{"1", "9"}, []string{"bug"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := makeForumLabels(available, tc.applied)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
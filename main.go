@@ -2,6 +2,7 @@ package main
 
 import (
 	"cmp"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -34,9 +35,13 @@ func main() {
 	appIDFlag := flag.String("app-id", "", "Discord app ID. Can be set by env.")
 	botTokenFlag := flag.String("bot-token", "", "Discord bot token. Can be set by env.")
 	logLevelFlag := flag.String("log-level", "info", "Set log level for this session. Can be set by env.")
-	resetCommandsFlag := flag.Bool("reset-commands", false, "Recreates Discord commands. Requires user re-install.")
+	devGuildFlag := flag.String("dev-guild", "", "Registers commands for this Discord guild only for testing changes instantly.")
 	versionFlag := flag.Bool("version", false, "Shows the version.")
 	exportFlag := flag.Bool("export", false, "export data as JSON")
+	guildInstallFlag := flag.Bool("guild-install", false, "Enables installing the app to servers with shared repos. Can be set by env.")
+	titleTemplateFlag := flag.String("title-template", "", "File with the default template for issue titles. Can be set by env.")
+	bodyTemplateFlag := flag.String("body-template", "", "File with the default template for issue bodies. Can be set by env.")
+	descriptionTemplateFlag := flag.String("description-template", "", "File with the default template for prefilling issue descriptions. Can be set by env.")
 	flag.Parse()
 
 	if *versionFlag {
@@ -78,6 +83,16 @@ func main() {
 	}
 	slog.SetLogLoggerLevel(l)
 
+	templates, err := loadIssueTemplates(
+		cmp.Or(*titleTemplateFlag, os.Getenv("TITLE_TEMPLATE")),
+		cmp.Or(*bodyTemplateFlag, os.Getenv("BODY_TEMPLATE")),
+		cmp.Or(*descriptionTemplateFlag, os.Getenv("DESCRIPTION_TEMPLATE")),
+	)
+	if err != nil {
+		slog.Error("Failed to load templates", "error", err)
+		os.Exit(1)
+	}
+
 	db, err := bolt.Open(dbName, 0600, nil)
 	if err != nil {
 		slog.Error("Failed to open database", "error", err)
@@ -123,6 +138,11 @@ func main() {
 		os.Exit(1)
 	}
 	ds.Identify.Intents = discordgo.IntentMessageContent
+	guildInstall := *guildInstallFlag || os.Getenv("GUILD_INSTALL") == "true"
+	if guildInstall {
+		// needed for forums, threads and reactions
+		ds.Identify.Intents |= discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsGuildMessageReactions
+	}
 	ds.UserAgent = fmt.Sprintf("%s (%s, %s)", name, repoURL, Version)
 
 	api := newRepoAPI()
@@ -130,19 +150,50 @@ func main() {
 		Timeout: time.Second * 5,
 	}
 
-	b := NewBot(st, ds, appID, api)
+	b := NewBot(st, ds, appID, api, guildInstall, templates)
 	if err := ds.Open(); err != nil {
 		slog.Error("Cannot open the Discord session", "error", err)
 		os.Exit(1)
 	}
 	defer ds.Close()
-	if err := b.InitCommands(*resetCommandsFlag); err != nil {
+	if err := b.InitCommands(*devGuildFlag); err != nil {
 		slog.Error("Failed to init Discord commands", "error", err)
 		os.Exit(1)
 	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	b.StartSessionJanitor(ctx)
+	if guildInstall {
+		b.StartCommentSync(ctx)
+	}
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	<-stop
+	<-ctx.Done()
 	slog.Info("Graceful shutdown")
 }
+
+// loadIssueTemplates returns the validated templates from the given files.
+// Files which are not given are skipped.
+func loadIssueTemplates(titlePath, bodyPath, descriptionPath string) (IssueTemplates, error) {
+	var t IssueTemplates
+	for _, x := range []struct {
+		path string
+		text *string
+	}{
+		{titlePath, &t.Title},
+		{bodyPath, &t.Body},
+		{descriptionPath, &t.Description},
+	} {
+		if x.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(x.path)
+		if err != nil {
+			return t, err
+		}
+		*x.text = string(data)
+	}
+	if err := t.Validate(); err != nil {
+		return t, err
+	}
+	return t, nil
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// testAllRepos is the value of the repo option for testing all repos.
+const testAllRepos = "all"
+
+// startAddRepo responds with the modal for adding a repo.
+func (b *Bot) startAddRepo(ic *discordgo.InteractionCreate, userID string) error {
+	n, err := b.st.CountReposForUser(userID)
+	if err != nil {
+		return err
+	}
+	if n >= maxReposPerUser {
+		return b.respondEphemeral(ic, "You have reached the upper limit of repos")
+	}
+	err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: makeRepoAddModalData(idRepoAdd2),
+	})
+	return err
+}
+
+// removeRepo removes a repo and responds with the result.
+func (b *Bot) removeRepo(ic *discordgo.InteractionCreate, userID string, repoID int) error {
+	r, err := b.getManageableRepo(ic, userID, repoID)
+	if errors.Is(err, ErrNotFound) {
+		return b.respondEphemeral(ic, ":x: You are not permitted to manage this repo")
+	} else if err != nil {
+		return err
+	}
+	if err := b.st.DeleteRepo(r.ID); err != nil {
+		return err
+	}
+	err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral | discordgo.MessageFlagsIsComponentsV2,
+			Components: []discordgo.MessageComponent{
+				discordgo.TextDisplay{
+					Content: fmt.Sprintf(":white_check_mark: Repo removed: %s", r.Name()),
+				},
+			},
+		},
+	})
+	return err
+}
+
+// testRepos checks the tokens of repos and responds with the results.
+func (b *Bot) testRepos(ic *discordgo.InteractionCreate, repos []*Repo) error {
+	err := b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var components []discordgo.MessageComponent
+	if len(repos) == 0 {
+		components = append(components, discordgo.TextDisplay{Content: "No repos to test"})
+	}
+	for _, r := range repos {
+		var s string
+		status, err := b.api.checkToken(r)
+		if err != nil {
+			slog.Warn("Failed to check token", "repo", r.Name(), "error", err)
+			var m string
+			switch status {
+			case http.StatusUnauthorized:
+				m = "Invalid token"
+			case http.StatusNotFound:
+				m = "Repository not found"
+			default:
+				m = "Internal error"
+			}
+			s = fmt.Sprintf(":x: Test failed: %s\nERROR: %s", r.Name(), m)
+		} else {
+			s = fmt.Sprintf(":white_check_mark: Test succeeded: %s", r.Name())
+		}
+		components = append(components, discordgo.TextDisplay{Content: s})
+	}
+	return b.sendComponentsPaged(ic, components)
+}
+
+// setDefaultRepo sets or removes the default repo of a user from the options of a sub command.
+func (b *Bot) setDefaultRepo(ic *discordgo.InteractionCreate, userID string, sub *discordgo.ApplicationCommandInteractionDataOption) error {
+	u, err := b.st.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	o := sub.GetOption(optRepo)
+	if o == nil {
+		if u.DefaultRepoID == 0 {
+			return b.respondEphemeral(ic, ":x: You have no default repo")
+		}
+		u.DefaultRepoID = 0
+		if err := b.st.UpdateUser(u); err != nil {
+			return err
+		}
+		return b.respondEphemeral(ic, ":white_check_mark: Default repo removed")
+	}
+	repos, err := b.listReposForInteraction(ic, userID)
+	if err != nil {
+		return err
+	}
+	r := findRepoByOption(repos, o.StringValue())
+	if r == nil {
+		return b.respondEphemeral(ic, ":x: Unknown repo: "+o.StringValue())
+	}
+	u.DefaultRepoID = r.ID
+	if err := b.st.UpdateUser(u); err != nil {
+		return err
+	}
+	return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: %s is now your default repo", r.Name()))
+}
+
+// showSettings responds with the settings of a user.
+func (b *Bot) showSettings(ic *discordgo.InteractionCreate, userID string) error {
+	u, err := b.st.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	repos, err := b.listReposForInteraction(ic, userID)
+	if err != nil {
+		return err
+	}
+	name := func(id int) string {
+		i := slices.IndexFunc(repos, func(r *Repo) bool { return r.ID == id })
+		if i == -1 {
+			return fmt.Sprintf("Unknown repo #%d", id)
+		}
+		return fmt.Sprintf("[%s](<%s>)", repos[i].Name(), repos[i].URL())
+	}
+	lines := []string{"## Settings"}
+	if u.DefaultRepoID != 0 {
+		lines = append(lines, "Default repo: "+name(u.DefaultRepoID))
+	} else {
+		lines = append(lines, "Default repo: -")
+	}
+	if len(u.FavoriteRepoIDs) == 0 {
+		lines = append(lines, "Favorite repos: -")
+	} else {
+		lines = append(lines, "Favorite repos:")
+		for _, id := range u.FavoriteRepoIDs {
+			lines = append(lines, "- "+name(id))
+		}
+	}
+	lines = append(lines, "-# Change with `/issuebot default` and `/issuebot favorite`")
+	return b.respondEphemeral(ic, strings.Join(lines, "\n"))
+}
+
+// listManageableRepos returns the user's own repos and the shared repos the user is permitted to manage.
+func (b *Bot) listManageableRepos(ic *discordgo.InteractionCreate, userID string) ([]*Repo, error) {
+	repos, err := b.st.ListReposForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := b.listManagedGuildRepos(ic)
+	if err != nil {
+		return nil, err
+	}
+	return append(repos, shared...), nil
+}
+
+// getManageableRepo returns a repo the user is permitted to manage.
+// Returns [ErrNotFound] when the repo does not exist or the user is not permitted to manage it.
+func (b *Bot) getManageableRepo(ic *discordgo.InteractionCreate, userID string, repoID int) (*Repo, error) {
+	r, err := b.st.GetRepo(repoID)
+	if err != nil {
+		return nil, err
+	}
+	if !r.IsShared() {
+		if r.UserID != userID {
+			return nil, ErrNotFound
+		}
+		return r, nil
+	}
+	if r.GuildID != ic.GuildID {
+		return nil, ErrNotFound
+	}
+	ok, err := b.hasPermission(ic, permManageRepos, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return r, nil
+}
+
+// findRepoByOption returns the repo from repos with the ID given as option value
+// or nil if not found.
+func findRepoByOption(repos []*Repo, value string) *Repo {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return nil
+	}
+	i := slices.IndexFunc(repos, func(r *Repo) bool { return r.ID == id })
+	if i == -1 {
+		return nil
+	}
+	return repos[i]
+}
+
+// startEditTemplates responds with the modal for changing the issue templates and the title prefix of a repo.
+func (b *Bot) startEditTemplates(ic *discordgo.InteractionCreate, userID string, repoID int) error {
+	r, err := b.getManageableRepo(ic, userID, repoID)
+	if errors.Is(err, ErrNotFound) {
+		return b.respondEphemeral(ic, ":x: You are not permitted to manage this repo")
+	} else if err != nil {
+		return err
+	}
+	err = b.ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: makeTemplatesModalData(fmt.Sprintf("%s%d", idRepoTemplate, r.ID), r.Templates),
+	})
+	return err
+}
+
+// saveTemplates validates and saves the issue templates of a repo from a submitted modal.
+func (b *Bot) saveTemplates(ic *discordgo.InteractionCreate, userID string, repoID int, data discordgo.ModalSubmitInteractionData) error {
+	r, err := b.getManageableRepo(ic, userID, repoID)
+	if errors.Is(err, ErrNotFound) {
+		return b.respondEphemeral(ic, ":x: You are not permitted to manage this repo")
+	} else if err != nil {
+		return err
+	}
+	value := func(i int) string {
+		return strings.TrimSpace(data.Components[i].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)
+	}
+	t := IssueTemplates{
+		TitlePrefix: value(0),
+		Title:       value(1),
+		Body:        value(2),
+		Description: value(3),
+	}
+	if err := t.Merge(b.templates).Validate(); err != nil {
+		return b.respondEphemeral(ic, fmt.Sprintf(":x: Invalid template: %s", err))
+	}
+	r.Templates = t
+	if err := b.st.UpdateRepo(r); err != nil {
+		return err
+	}
+	if t.IsEmpty() {
+		return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: %s now uses the default templates", r.Name()))
+	}
+	return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: Templates saved for %s", r.Name()))
+}
+
+// makeTemplatesModalData returns the modal for changing issue templates.
+func makeTemplatesModalData(customID string, t IssueTemplates) *discordgo.InteractionResponseData {
+	return &discordgo.InteractionResponseData{
+		CustomID: customID,
+		Title:    "Issue templates",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:    "titlePrefix",
+						Label:       "Title prefix",
+						MaxLength:   maxTitlePrefixLength,
+						Placeholder: "Prefix for suggested titles, e.g. [Discord]",
+						Style:       discordgo.TextInputShort,
+						Value:       t.TitlePrefix,
+					},
+				},
+			},
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:    "title",
+						Label:       "Title template",
+						Placeholder: "Leave empty for the default, e.g. [Discord] {{.Title}}",
+						Style:       discordgo.TextInputShort,
+						Value:       t.Title,
+					},
+				},
+			},
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:    "body",
+						Label:       "Body template",
+						Placeholder: "Leave empty for the default",
+						Style:       discordgo.TextInputParagraph,
+						Value:       t.Body,
+					},
+				},
+			},
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:    "description",
+						Label:       "Description template",
+						Placeholder: "Prefills the description, e.g. with questions for bug reports",
+						Style:       discordgo.TextInputParagraph,
+						Value:       t.Description,
+					},
+				},
+			},
+		},
+	}
+}
+
+// setUploadLongBodies enables or disables uploading the full text of too long issues for a repo.
+func (b *Bot) setUploadLongBodies(ic *discordgo.InteractionCreate, userID string, repoID int, enabled bool) error {
+	r, err := b.getManageableRepo(ic, userID, repoID)
+	if errors.Is(err, ErrNotFound) {
+		return b.respondEphemeral(ic, ":x: You are not permitted to manage this repo")
+	} else if err != nil {
+		return err
+	}
+	r.UploadLongBodies = enabled
+	if err := b.st.UpdateRepo(r); err != nil {
+		return err
+	}
+	if !enabled {
+		return b.respondEphemeral(ic, fmt.Sprintf(":white_check_mark: Too long issues for %s are truncated", r.Name()))
+	}
+	var kind string
+	switch r.Vendor {
+	case gitLab:
+		kind = "private snippet of the repo"
+	default:
+		kind = "secret gist"
+	}
+	return b.respondEphemeral(ic, fmt.Sprintf(
+		":white_check_mark: The full text of too long issues for %s is uploaded as %s", r.Name(), kind,
+	))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestFindRepoByOption(t *testing.T) {
+	repos := []*Repo{{ID: 1}, {ID: 2}}
+	cases := []struct {
+		value string
+		want  *Repo
+	}{
+		{"2", repos[1]},
+		{"3", nil},
+		{"all", nil},
+		{"", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.value, func(t *testing.T) {
+			assert.Equal(t, tc.want, findRepoByOption(repos, tc.value))
+		})
+	}
+}
+
+func TestGetManageableRepo(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(p, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %s", err)
+	}
+	defer db.Close()
+	st := NewStorage(db)
+	if err = st.Init(); err != nil {
+		t.Fatal(err)
+	}
+	own := createRepo(t, st, UpdateOrCreateRepoParams{UserID: "user1"})
+	shared := createRepo(t, st, UpdateOrCreateRepoParams{UserID: "user2", GuildID: "guild1"})
+	makeInteraction := func(guildID string, member *discordgo.Member) *discordgo.InteractionCreate {
+		return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			GuildID: guildID,
+			Member:  member,
+			AuthorizingIntegrationOwners: map[discordgo.ApplicationIntegrationType]string{
+				discordgo.ApplicationIntegrationGuildInstall: "guild1",
+			},
+		}}
+	}
+	manager := &discordgo.Member{User: &discordgo.User{ID: "user3"}, Permissions: discordgo.PermissionManageGuild}
+	member := &discordgo.Member{User: &discordgo.User{ID: "user1"}}
+	cases := []struct {
+		name    string
+		ic      *discordgo.InteractionCreate
+		userID  string
+		repoID  int
+		wantErr error
+	}{
+		{"owner of repo", makeInteraction("", nil), "user1", own.ID, nil},
+		{"other user", makeInteraction("", nil), "user2", own.ID, ErrNotFound},
+		{"manager of shared repo", makeInteraction("guild1", manager), "user3", shared.ID, nil},
+		{"member without permission", makeInteraction("guild1", member), "user1", shared.ID, ErrNotFound},
+		{"shared repo from other guild", makeInteraction("guild2", manager), "user3", shared.ID, ErrNotFound},
+		{"creator of shared repo outside guild", makeInteraction("", nil), "user2", shared.ID, ErrNotFound},
+		{"unknown repo", makeInteraction("", nil), "user1", 999, ErrNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &Bot{guildInstall: true, st: st}
+			r, err := b.getManageableRepo(tc.ic, tc.userID, tc.repoID)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, tc.repoID, r.ID)
+			}
+		})
+	}
+}
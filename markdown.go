@@ -0,0 +1,349 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// mentionNames contains the names for resolving mentions in Discord messages by ID.
+type mentionNames struct {
+	channels map[string]string
+	roles    map[string]string
+	users    map[string]string
+}
+
+// makeMentionNames returns the names for the mentions in a Discord message.
+// Names are taken from the message, the resolved data of an interaction, which can be nil,
+// and the state cache.
+func (b *Bot) makeMentionNames(guildID string, m *discordgo.Message, resolved *discordgo.ApplicationCommandInteractionDataResolved) mentionNames {
+	names := mentionNames{
+		channels: make(map[string]string),
+		roles:    make(map[string]string),
+		users:    make(map[string]string),
+	}
+	messages := []*discordgo.Message{m}
+	for _, x := range m.MessageSnapshots {
+		if x.Message != nil {
+			messages = append(messages, x.Message)
+		}
+	}
+	for _, m := range messages {
+		for _, u := range m.Mentions {
+			names.users[u.ID] = u.DisplayName()
+		}
+		for _, c := range m.MentionChannels {
+			names.channels[c.ID] = c.Name
+		}
+	}
+	if resolved != nil {
+		for id, u := range resolved.Users {
+			names.users[id] = u.DisplayName()
+		}
+		for id, x := range resolved.Members {
+			if x.Nick != "" {
+				names.users[id] = x.Nick
+			}
+		}
+		for id, r := range resolved.Roles {
+			names.roles[id] = r.Name
+		}
+		for id, c := range resolved.Channels {
+			names.channels[id] = c.Name
+		}
+	}
+	if b.ds == nil || b.ds.State == nil {
+		return names
+	}
+	if guildID != "" {
+		for _, id := range m.MentionRoles {
+			if _, ok := names.roles[id]; ok {
+				continue
+			}
+			if r, err := b.ds.State.Role(guildID, id); err == nil {
+				names.roles[id] = r.Name
+			}
+		}
+	}
+	text := strings.Join(messageTexts(m), "\n")
+	if guildID != "" {
+		for _, x := range userMentionRx.FindAllStringSubmatch(text, -1) {
+			id := x[1]
+			if _, ok := names.users[id]; ok {
+				continue
+			}
+			if member, err := b.ds.State.Member(guildID, id); err == nil && member.User != nil {
+				names.users[id] = cmp.Or(member.Nick, member.User.DisplayName())
+			}
+		}
+	}
+	for _, x := range channelMentionRx.FindAllStringSubmatch(text, -1) {
+		id := x[1]
+		if _, ok := names.channels[id]; ok {
+			continue
+		}
+		if c, err := b.ds.State.Channel(id); err == nil {
+			names.channels[id] = c.Name
+		}
+	}
+	return names
+}
+
+var (
+	boldRx           = regexp.MustCompile(`(\*\*|__|~~)(.+?)(\*\*|__|~~)`)
+	htmlTagRx        = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	linePrefixRx     = regexp.MustCompile(`^(>\s?|#{1,3}\s+|[-*]\s+|\d+\.\s+)+`)
+	linkRx           = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	italicRx         = regexp.MustCompile(`(^|\W)[*_](\S(?:.*?\S)?)[*_](\W|$)`)
+	channelMentionRx = regexp.MustCompile(`<#(\d+)>`)
+	codeRx           = regexp.MustCompile("(?s)```.*?```|`[^`\n]+`")
+	customEmojiMdRx  = regexp.MustCompile(`<a?:(\w+):\d+>`)
+	roleMentionRx    = regexp.MustCompile(`<@&(\d+)>`)
+	spoilerRx        = regexp.MustCompile(`(?s)\|\|(.+?)\|\|`)
+	subtextRx        = regexp.MustCompile(`(?m)^-# `)
+	timestampRx      = regexp.MustCompile(`<t:(-?\d+)(?::([tTdDfFR]))?>`)
+	userMentionRx    = regexp.MustCompile(`<@!?(\d+)>`)
+)
+
+// messageTexts returns all texts of a Discord message which can contain mentions,
+// including the texts of embeds and forwarded messages.
+func messageTexts(m *discordgo.Message) []string {
+	texts := []string{m.Content}
+	for _, e := range m.Embeds {
+		texts = append(texts, e.Description)
+		for _, f := range e.Fields {
+			texts = append(texts, f.Value)
+		}
+	}
+	for _, x := range m.MessageSnapshots {
+		if x.Message != nil {
+			texts = append(texts, messageTexts(x.Message)...)
+		}
+	}
+	return texts
+}
+
+// convertMessage converts a Discord message into markdown for GitHub and GitLab.
+// Besides the content this includes rich embeds, e.g. alerts from other bots,
+// forwarded messages, polls and stickers. Attachments are not included.
+func convertMessage(m *discordgo.Message, names mentionNames) string {
+	var parts []string
+	if m.Content != "" {
+		parts = append(parts, foldCodeBlocks(convertMarkdown(m.Content, names)))
+	}
+	for _, e := range m.Embeds {
+		if s := convertEmbed(e, names); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	for _, x := range m.MessageSnapshots {
+		if x.Message == nil {
+			continue
+		}
+		lines := []string{convertMessage(x.Message, names)}
+		for _, a := range x.Message.Attachments {
+			lines = append(lines, "- "+linkAttachment(newAttachment(a)))
+		}
+		s := strings.TrimSpace(strings.Join(lines, "\n"))
+		if s == "" {
+			continue
+		}
+		parts = append(parts, "*Forwarded message:*\n"+quoteMarkdown(s))
+	}
+	if m.Poll != nil {
+		parts = append(parts, convertPoll(m.Poll, names))
+	}
+	for _, x := range m.StickerItems {
+		parts = append(parts, convertSticker(x))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// convertEmbed converts a rich embed into markdown.
+// Other embeds like link previews are skipped, since they are generated from the content.
+func convertEmbed(e *discordgo.MessageEmbed, names mentionNames) string {
+	if e.Type != "" && e.Type != discordgo.EmbedTypeRich {
+		return ""
+	}
+	var parts []string
+	if e.Author != nil && e.Author.Name != "" {
+		parts = append(parts, fmt.Sprintf("**%s**", e.Author.Name))
+	}
+	if e.Title != "" {
+		if e.URL != "" {
+			parts = append(parts, fmt.Sprintf("### [%s](%s)", e.Title, e.URL))
+		} else {
+			parts = append(parts, "### "+e.Title)
+		}
+	}
+	if e.Description != "" {
+		parts = append(parts, convertMarkdown(e.Description, names))
+	}
+	for _, f := range e.Fields {
+		parts = append(parts, fmt.Sprintf("**%s**\n%s", f.Name, convertMarkdown(f.Value, names)))
+	}
+	if e.Image != nil && e.Image.URL != "" {
+		parts = append(parts, fmt.Sprintf("![image](%s)", e.Image.URL))
+	}
+	if e.Footer != nil && e.Footer.Text != "" {
+		parts = append(parts, fmt.Sprintf("*%s*", e.Footer.Text))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// convertPoll converts a poll into markdown with the results when available.
+func convertPoll(p *discordgo.Poll, names mentionNames) string {
+	counts := make(map[int]int)
+	if p.Results != nil {
+		for _, c := range p.Results.AnswerCounts {
+			counts[c.ID] = c.Count
+		}
+	}
+	lines := []string{"**Poll: " + convertMarkdown(p.Question.Text, names) + "**"}
+	for _, a := range p.Answers {
+		if a.Media == nil {
+			continue
+		}
+		l := "- " + convertMarkdown(a.Media.Text, names)
+		if p.Results != nil {
+			l += fmt.Sprintf(" (%d votes)", counts[a.AnswerID])
+		}
+		lines = append(lines, l)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// convertSticker converts a sticker into markdown.
+// Stickers are shown as images, except for Lottie stickers, which can only be shown by Discord.
+func convertSticker(x *discordgo.StickerItem) string {
+	var ext string
+	switch x.FormatType {
+	case discordgo.StickerFormatTypePNG, discordgo.StickerFormatTypeAPNG:
+		ext = "png"
+	case discordgo.StickerFormatTypeGIF:
+		ext = "gif"
+	default:
+		return fmt.Sprintf("*Sticker: %s*", x.Name)
+	}
+	return fmt.Sprintf("![Sticker: %s](https://media.discordapp.net/stickers/%s.%s)", x.Name, x.ID, ext)
+}
+
+// convertMarkdown converts the content of a Discord message into markdown for GitHub and GitLab.
+// Mentions are replaced by names, custom emojis by their names and timestamps by ISO dates.
+// Code is kept as is.
+func convertMarkdown(content string, names mentionNames) string {
+	var sb strings.Builder
+	var start int
+	for _, loc := range codeRx.FindAllStringIndex(content, -1) {
+		sb.WriteString(convertMarkdownText(content[start:loc[0]], names))
+		sb.WriteString(content[loc[0]:loc[1]])
+		start = loc[1]
+	}
+	sb.WriteString(convertMarkdownText(content[start:], names))
+	return sb.String()
+}
+
+// convertMarkdownText converts Discord markdown in text without code.
+func convertMarkdownText(s string, names mentionNames) string {
+	mention := func(prefix, fallback string, m map[string]string, id string) string {
+		name, ok := m[id]
+		if !ok {
+			name = fallback
+		}
+		return "`" + prefix + name + "`" // code prevents mentions on GitHub and GitLab
+	}
+	s = userMentionRx.ReplaceAllStringFunc(s, func(x string) string {
+		return mention("@", "unknown-user", names.users, userMentionRx.FindStringSubmatch(x)[1])
+	})
+	s = roleMentionRx.ReplaceAllStringFunc(s, func(x string) string {
+		return mention("@", "unknown-role", names.roles, roleMentionRx.FindStringSubmatch(x)[1])
+	})
+	s = channelMentionRx.ReplaceAllStringFunc(s, func(x string) string {
+		return mention("#", "unknown-channel", names.channels, channelMentionRx.FindStringSubmatch(x)[1])
+	})
+	s = customEmojiMdRx.ReplaceAllString(s, ":$1:")
+	s = timestampRx.ReplaceAllStringFunc(s, func(x string) string {
+		m := timestampRx.FindStringSubmatch(x)
+		v, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return x
+		}
+		t := time.Unix(v, 0).UTC()
+		switch m[2] {
+		case "d", "D":
+			return t.Format(time.DateOnly)
+		}
+		return t.Format(time.RFC3339)
+	})
+	s = spoilerRx.ReplaceAllString(s, "<details><summary>Spoiler</summary>$1</details>")
+	s = subtextRx.ReplaceAllString(s, "")
+	return s
+}
+
+// suggestTitle returns a title for an issue suggested from markdown content,
+// e.g. a converted Discord message.
+// The title is the first line of text with the markdown stripped
+// and is truncated at a word boundary to fit within maxLength characters.
+func suggestTitle(content string, maxLength int) string {
+	var inCode bool
+	for l := range strings.Lines(content) {
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if title := stripMarkdown(l); title != "" {
+			return truncateAtWord(title, maxLength)
+		}
+	}
+	return ""
+}
+
+// stripMarkdown returns a line of markdown as plain text.
+func stripMarkdown(s string) string {
+	s = linePrefixRx.ReplaceAllString(s, "")
+	s = linkRx.ReplaceAllString(s, "$1")
+	s = htmlTagRx.ReplaceAllString(s, " ")
+	s = boldRx.ReplaceAllString(s, "$2")
+	s = italicRx.ReplaceAllString(s, "$1$2$3")
+	s = strings.ReplaceAll(s, "`", "")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncateAtWord returns s truncated to at most maxLength characters.
+// Truncated strings are cut at the last word boundary if possible and end with an ellipsis.
+func truncateAtWord(s string, maxLength int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLength {
+		return s
+	}
+	if maxLength < 1 {
+		return ""
+	}
+	cut := string(runes[:maxLength-1])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ") + "…"
+}
+
+// quoteMarkdown returns markdown as a block quote.
+func quoteMarkdown(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertMarkdown(t *testing.T) {
+	names := mentionNames{
+		channels: map[string]string{"30": "general"},
+		roles:    map[string]string{"20": "moderators"},
+		users:    map[string]string{"10": "Alice"},
+	}
+	cases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain text", "hello world", "hello world"},
+		{"user mention", "thanks <@10>!", "thanks `@Alice`!"},
+		{"user mention with nickname syntax", "<@!10>", "`@Alice`"},
+		{"unknown user mention", "<@11>", "`@unknown-user`"},
+		{"role mention", "<@&20> please check", "`@moderators` please check"},
+		{"unknown role mention", "<@&21>", "`@unknown-role`"},
+		{"channel mention", "see <#30>", "see `#general`"},
+		{"unknown channel mention", "<#31>", "`#unknown-channel`"},
+		{"custom emoji", "nice <:party:123456>", "nice :party:"},
+		{"animated custom emoji", "<a:dance:123456>", ":dance:"},
+		{"unicode emoji", "nice 🎉", "nice 🎉"},
+		{"timestamp without style", "<t:1700000000>", "2023-11-14T22:13:20Z"},
+		{"relative timestamp", "since <t:1700000000:R>", "since 2023-11-14T22:13:20Z"},
+		{"date timestamp", "<t:1700000000:d>", "2023-11-14"},
+		{"long date timestamp", "<t:1700000000:D>", "2023-11-14"},
+		{"time timestamp", "<t:1700000000:t>", "2023-11-14T22:13:20Z"},
+		{"invalid timestamp style", "<t:1700000000:x>", "<t:1700000000:x>"},
+		{"spoiler", "the answer is ||42||", "the answer is <details><summary>Spoiler</summary>42</details>"},
+		{"subtext", "-# small print", "small print"},
+		{"subtext only at line start", "a -# b", "a -# b"},
+		{"inline code is kept", "run `<@10> <t:1700000000>` now <@10>", "run `<@10> <t:1700000000>` now `@Alice`"},
+		{
+			"code block is kept",
+			"before <@10>\n```go\nfmt.Println(\"<@10> ||x||\")\n```\nafter <@10>",
+			"before `@Alice`\n```go\nfmt.Println(\"<@10> ||x||\")\n```\nafter `@Alice`",
+		},
+		{"multiple mentions", "<@10> <@10> <#30>", "`@Alice` `@Alice` `#general`"},
+		{"markdown is kept", "**bold** _italic_ [link](https://example.com)", "**bold** _italic_ [link](https://example.com)"},
+		{"empty", "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, convertMarkdown(tc.content, names))
+		})
+	}
+}
+
+func TestQuoteMarkdown(t *testing.T) {
+	cases := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"single line", "hello", "> hello"},
+		{"every line is quoted", "first\nsecond\nthird", "> first\n> second\n> third"},
+		{"empty lines", "first\n\nsecond", "> first\n>\n> second"},
+		{"code block", "```\ncode\n```", "> ```\n> code\n> ```"},
+		{"nested quote", "> quoted", "> > quoted"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, quoteMarkdown(tc.s))
+		})
+	}
+}
+
+func TestMakeMentionNames(t *testing.T) {
+	var b Bot
+	m := &discordgo.Message{
+		Content:         "<@1> <@2> <#3>",
+		Mentions:        []*discordgo.User{{ID: "1", Username: "alice"}, {ID: "2", Username: "bob", GlobalName: "Bob"}},
+		MentionChannels: []*discordgo.Channel{{ID: "3", Name: "general"}},
+	}
+	resolved := &discordgo.ApplicationCommandInteractionDataResolved{
+		Members: map[string]*discordgo.Member{"1": {Nick: "Ally"}},
+		Roles:   map[string]*discordgo.Role{"4": {ID: "4", Name: "mods"}},
+	}
+	m.MessageSnapshots = []discordgo.MessageSnapshot{{Message: &discordgo.Message{
+		Content:  "<@5>",
+		Mentions: []*discordgo.User{{ID: "5", Username: "carol"}},
+	}}}
+	got := b.makeMentionNames("guild1", m, resolved)
+	assert.Equal(t, map[string]string{"1": "Ally", "2": "Bob", "5": "carol"}, got.users)
+	assert.Equal(t, map[string]string{"3": "general"}, got.channels)
+	assert.Equal(t, map[string]string{"4": "mods"}, got.roles)
+}
+
+func TestSuggestTitle(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"first line", "  The app crashes\nwhen I click", "The app crashes"},
+		{"empty", "", ""},
+		{"skips empty lines", "\n\n  \nThe app crashes", "The app crashes"},
+		{"strips emphasis", "**The app** _crashes_ ~~often~~ *always*", "The app crashes often always"},
+		{"keeps underscores in words", "my_var_name is nil", "my_var_name is nil"},
+		{"strips headings", "## The app crashes", "The app crashes"},
+		{"strips quotes and lists", "> - The app crashes", "The app crashes"},
+		{"strips links", "See [the docs](https://example.com) please", "See the docs please"},
+		{"strips inline code", "`@Alice` found a bug", "@Alice found a bug"},
+		{"strips spoilers", "<details><summary>Spoiler</summary>secret</details> ending", "Spoiler secret ending"},
+		{"skips code blocks", "```\ncode\n```\nThe app crashes", "The app crashes"},
+		{"collapses whitespace", "The   app\tcrashes", "The app crashes"},
+		{"only markup", "**  **\n---", "---"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, suggestTitle(tc.content, maxTitleLength))
+		})
+	}
+	t.Run("truncates long titles at word boundary", func(t *testing.T) {
+		content := strings.Repeat("word ", 100)
+		got := suggestTitle(content, maxTitleLength)
+		assert.LessOrEqual(t, utf8.RuneCountInString(got), maxTitleLength)
+		assert.True(t, strings.HasSuffix(got, "word…"), got)
+	})
+}
+
+func TestTruncateAtWord(t *testing.T) {
+	cases := []struct {
+		name      string
+		s         string
+		maxLength int
+		want      string
+	}{
+		{"short", "The app crashes", 20, "The app crashes"},
+		{"exact", "The app crashes", 15, "The app crashes"},
+		{"word boundary", "The app crashes often", 15, "The app…"},
+		{"long word", strings.Repeat("x", 20), 10, strings.Repeat("x", 9) + "…"},
+		{"multi-byte", "äöü äöü äöü", 6, "äöü…"},
+		{"zero", "The app", 0, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := truncateAtWord(tc.s, tc.maxLength)
+			assert.Equal(t, tc.want, got)
+			assert.LessOrEqual(t, utf8.RuneCountInString(got), tc.maxLength)
+		})
+	}
+}
+
+func TestConvertMessage(t *testing.T) {
+	names := mentionNames{users: map[string]string{"10": "Alice"}}
+	cases := []struct {
+		name string
+		m    *discordgo.Message
+		want string
+	}{
+		{"content", &discordgo.Message{Content: "hello <@10>"}, "hello `@Alice`"},
+		{
+			"rich embed",
+			&discordgo.Message{Embeds: []*discordgo.MessageEmbed{{
+				Type:        discordgo.EmbedTypeRich,
+				Author:      &discordgo.MessageEmbedAuthor{Name: "Monitor"},
+				Title:       "Crash in worker",
+				URL:         "https://example.com/crash/1",
+				Description: "Worker **7** crashed, ping <@10>",
+				Fields: []*discordgo.MessageEmbedField{
+					{Name: "Error", Value: "`nil pointer`"},
+					{Name: "Host", Value: "app-1", Inline: true},
+				},
+				Image:  &discordgo.MessageEmbedImage{URL: "https://example.com/graph.png"},
+				Footer: &discordgo.MessageEmbedFooter{Text: "v1.2.3"},
+			}}},
+			"**Monitor**\n\n### [Crash in worker](https://example.com/crash/1)\n\n" +
+				"Worker **7** crashed, ping `@Alice`\n\n**Error**\n`nil pointer`\n\n**Host**\napp-1\n\n" +
+				"![image](https://example.com/graph.png)\n\n*v1.2.3*",
+		},
+		{
+			"embed without title",
+			&discordgo.Message{Embeds: []*discordgo.MessageEmbed{{Description: "alert"}}},
+			"alert",
+		},
+		{
+			"link preview is skipped",
+			&discordgo.Message{
+				Content: "https://example.com",
+				Embeds:  []*discordgo.MessageEmbed{{Type: discordgo.EmbedTypeLink, Title: "Example"}},
+			},
+			"https://example.com",
+		},
+		{
+			"forwarded message",
+			&discordgo.Message{MessageSnapshots: []discordgo.MessageSnapshot{{Message: &discordgo.Message{
+				Content:     "first\n\nsecond <@10>",
+				Attachments: []*discordgo.MessageAttachment{{Filename: "log.txt", URL: "https://cdn.discordapp.com/log.txt"}},
+			}}}},
+			"*Forwarded message:*\n> first\n>\n> second `@Alice`\n> - [log.txt](https://cdn.discordapp.com/log.txt) *(temporary link)*",
+		},
+		{
+			"content and forwarded message",
+			&discordgo.Message{
+				Content:          "see this",
+				MessageSnapshots: []discordgo.MessageSnapshot{{Message: &discordgo.Message{Content: "original"}}},
+			},
+			"see this\n\n*Forwarded message:*\n> original",
+		},
+		{
+			"empty forwarded message is skipped",
+			&discordgo.Message{MessageSnapshots: []discordgo.MessageSnapshot{{Message: &discordgo.Message{}}, {}}},
+			"",
+		},
+		{
+			"poll",
+			&discordgo.Message{Poll: &discordgo.Poll{
+				Question: discordgo.PollMedia{Text: "Which version?"},
+				Answers: []discordgo.PollAnswer{
+					{AnswerID: 1, Media: &discordgo.PollMedia{Text: "v1"}},
+					{AnswerID: 2, Media: &discordgo.PollMedia{Text: "v2"}},
+				},
+			}},
+			"**Poll: Which version?**\n- v1\n- v2",
+		},
+		{
+			"poll with results",
+			&discordgo.Message{Poll: &discordgo.Poll{
+				Question: discordgo.PollMedia{Text: "Which version?"},
+				Answers: []discordgo.PollAnswer{
+					{AnswerID: 1, Media: &discordgo.PollMedia{Text: "v1"}},
+					{AnswerID: 2, Media: &discordgo.PollMedia{Text: "v2"}},
+				},
+				Results: &discordgo.PollResults{AnswerCounts: []*discordgo.PollAnswerCount{{ID: 2, Count: 3}}},
+			}},
+			"**Poll: Which version?**\n- v1 (0 votes)\n- v2 (3 votes)",
+		},
+		{
+			"stickers",
+			&discordgo.Message{StickerItems: []*discordgo.StickerItem{
+				{ID: "1", Name: "wave", FormatType: discordgo.StickerFormatTypePNG},
+				{ID: "2", Name: "dance", FormatType: discordgo.StickerFormatTypeGIF},
+				{ID: "3", Name: "party", FormatType: discordgo.StickerFormatTypeLottie},
+			}},
+			"![Sticker: wave](https://media.discordapp.net/stickers/1.png)\n\n" +
+				"![Sticker: dance](https://media.discordapp.net/stickers/2.gif)\n\n" +
+				"*Sticker: party*",
+		},
+		{"empty", &discordgo.Message{}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, convertMessage(tc.m, names))
+		})
+	}
+}
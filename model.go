@@ -1,8 +1,12 @@
 package main
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
+	"time"
 )
 
 // Vendor represents a vendor that provides git repositories like GitHub.
@@ -17,6 +21,15 @@ func (v Vendor) String() string {
 	return string(v)
 }
 
+// MaxBodyLength returns the max number of characters in the body of an issue.
+func (v Vendor) MaxBodyLength() int {
+	switch v {
+	case gitLab:
+		return 1_048_576
+	}
+	return 65_536
+}
+
 func (v Vendor) Host() string {
 	switch v {
 	case gitHub:
@@ -28,19 +41,31 @@ func (v Vendor) Host() string {
 }
 
 // Repo represents a repository for creating issues.
+//
+// A repo belongs either to a single user or, when it has a guild ID,
+// is shared with all permitted members of that guild.
 type Repo struct {
-	ID     int    `json:"id"`
-	Repo   string `json:"repo"`
-	Owner  string `json:"owner"`
-	Token  string `json:"token"`
-	UserID string `json:"user_id"` // Discord user ID
-	Vendor Vendor `json:"vendor"`
+	ID        int            `json:"id"`
+	GuildID   string         `json:"guild_id,omitempty"` // Discord guild ID for shared repos
+	Repo      string         `json:"repo"`
+	Owner     string         `json:"owner"`
+	Token     string         `json:"token"`
+	UserID    string         `json:"user_id"` // Discord user ID
+	Vendor    Vendor         `json:"vendor"`
+	Templates IssueTemplates `json:"templates,omitzero"` // templates for new issues
+
+	UploadLongBodies bool `json:"upload_long_bodies,omitempty"` // whether too long issues are uploaded as gist or snippet
 }
 
 func (r Repo) isValid() bool {
 	return r.Owner != "" && r.Repo != "" && r.Token != "" && r.Vendor != "" && r.UserID != ""
 }
 
+// IsShared reports whether a repo is shared with a guild.
+func (r Repo) IsShared() bool {
+	return r.GuildID != ""
+}
+
 func (r Repo) Name() string {
 	s, _ := url.JoinPath(r.Vendor.Host(), r.Owner, r.Repo)
 	return s
@@ -50,3 +75,277 @@ func (r Repo) URL() string {
 	s, _ := url.JoinPath(r.Vendor.Host(), r.Owner, r.Repo)
 	return fmt.Sprintf("https://%s", s)
 }
+
+// Issue represents an issue which was created on a repo from a Discord message.
+type Issue struct {
+	ID               int       `json:"id"`
+	AuthorID         string    `json:"author_id"` // Discord user ID of the message author
+	ChannelID        string    `json:"channel_id"`
+	CommentsSyncedAt time.Time `json:"comments_synced_at"` // last time comments were synced with the thread
+	CreatedAt        time.Time `json:"created_at"`
+	GuildID          string    `json:"guild_id"`
+	LastCommentID    int       `json:"last_comment_id,omitempty"` // ID of the last vendor comment synced to the thread
+	MessageID        string    `json:"message_id"`
+	MessageTimestamp time.Time `json:"message_timestamp"`
+	Number           int       `json:"number"` // issue number on the vendor
+	RepoID           int       `json:"repo_id"`
+	SyncStopped      bool      `json:"sync_stopped,omitempty"` // whether comments are no longer synced with the thread
+	ThreadID         string    `json:"thread_id,omitempty"`    // Discord thread the issue was created from
+	Title            string    `json:"title"`
+	URL              string    `json:"url"`
+	UserID           string    `json:"user_id"` // Discord user ID of the user who created the issue
+}
+
+// Guild represents the configuration of a Discord guild, which has installed the app.
+type Guild struct {
+	ID              string           `json:"id"`
+	ChannelRepos    map[string]int   `json:"channel_repos,omitempty"`     // default repo IDs by channel or category ID
+	Forums          map[string]Forum `json:"forums,omitempty"`            // watched forums by channel ID
+	ReactionEmoji   string           `json:"reaction_emoji,omitempty"`    // emoji for creating issues by reacting in API format
+	ReviewChannelID string           `json:"review_channel_id,omitempty"` // channel for reviewing submitted issues
+	Rules           []PermissionRule `json:"rules,omitempty"`
+
+	// Deprecated: Replaced by rules. Converted into rules when a guild is loaded.
+	ReporterRoleIDs []string `json:"reporter_role_ids,omitempty"`
+}
+
+// migrateReporterRoles converts the reporter roles of older configurations into rules,
+// which permit creating issues with all shared repos.
+func (g *Guild) migrateReporterRoles() {
+	for _, id := range g.ReporterRoleIDs {
+		g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: id})
+	}
+	g.ReporterRoleIDs = nil
+}
+
+// SetChannelRepo sets the default repo for a channel or category.
+// Repo ID 0 removes the default repo.
+func (g *Guild) SetChannelRepo(channelID string, repoID int) {
+	if repoID == 0 {
+		delete(g.ChannelRepos, channelID)
+		return
+	}
+	if g.ChannelRepos == nil {
+		g.ChannelRepos = make(map[string]int)
+	}
+	g.ChannelRepos[channelID] = repoID
+}
+
+// ChannelRepo returns the ID of the default repo for a channel and reports whether it was found.
+// Channels are checked in the given order, e.g. a channel followed by its category.
+func (g *Guild) ChannelRepo(channelIDs ...string) (int, bool) {
+	for _, id := range channelIDs {
+		if repoID, ok := g.ChannelRepos[id]; ok {
+			return repoID, true
+		}
+	}
+	return 0, false
+}
+
+// AddRule adds a permission rule to a guild and returns it.
+// Adding a rule which already exists returns the existing rule.
+func (g *Guild) AddRule(arg PermissionRule) PermissionRule {
+	var maxID int
+	for _, x := range g.Rules {
+		if x.Permission == arg.Permission && x.RepoID == arg.RepoID && x.RoleID == arg.RoleID && x.UserID == arg.UserID {
+			return x
+		}
+		maxID = max(maxID, x.ID)
+	}
+	arg.ID = maxID + 1
+	g.Rules = append(g.Rules, arg)
+	return arg
+}
+
+// RemoveRule removes a permission rule from a guild and reports whether it was found.
+func (g *Guild) RemoveRule(id int) bool {
+	n := len(g.Rules)
+	g.Rules = slices.DeleteFunc(g.Rules, func(x PermissionRule) bool {
+		return x.ID == id
+	})
+	return len(g.Rules) != n
+}
+
+// IsPermitted reports whether a member with the given roles has a permission for a shared repo.
+// Repo ID 0 checks for rules which apply to all shared repos of the guild.
+func (g *Guild) IsPermitted(p Permission, repoID int, userID string, roleIDs []string) bool {
+	for _, x := range g.Rules {
+		if x.Permission != p || (x.RepoID != 0 && x.RepoID != repoID) {
+			continue
+		}
+		if (x.UserID != "" && x.UserID == userID) || (x.RoleID != "" && slices.Contains(roleIDs, x.RoleID)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetForum sets the configuration for watching a forum channel.
+// A configuration with repo ID 0 stops watching the forum.
+func (g *Guild) SetForum(channelID string, f Forum) {
+	if f.RepoID == 0 {
+		delete(g.Forums, channelID)
+		return
+	}
+	if g.Forums == nil {
+		g.Forums = make(map[string]Forum)
+	}
+	g.Forums[channelID] = f
+}
+
+// Forum represents the configuration of a forum channel, where new posts are turned into issues.
+type Forum struct {
+	RepoID          int  `json:"repo_id"`
+	RequireApproval bool `json:"require_approval,omitempty"` // whether new posts are submitted for review
+}
+
+// Permission represents the permission to perform actions with shared repos.
+type Permission string
+
+const (
+	permCreateIssues Permission = "create" // create issues directly
+	permManageRepos  Permission = "manage" // add, remove and test shared repos
+	permSubmitIssues Permission = "submit" // submit issues for review by someone who can create issues
+)
+
+func (p Permission) Display() string {
+	switch p {
+	case permCreateIssues:
+		return "Create issues"
+	case permManageRepos:
+		return "Manage repos"
+	case permSubmitIssues:
+		return "Submit issues for review"
+	}
+	return ""
+}
+
+// PermissionRule grants a permission to a Discord role or user
+// for all shared repos of a guild or for a specific repo.
+type PermissionRule struct {
+	ID         int        `json:"id"`
+	Permission Permission `json:"permission"`
+	RepoID     int        `json:"repo_id,omitempty"` // 0 = all shared repos
+	RoleID     string     `json:"role_id,omitempty"`
+	UserID     string     `json:"user_id,omitempty"`
+}
+
+// User represents the settings of a Discord user.
+type User struct {
+	ID              string          `json:"id"`
+	Basket          []BasketMessage `json:"basket,omitempty"`          // messages collected for creating one issue
+	DefaultRepoID   int             `json:"default_repo_id,omitempty"` // repo preselected when creating issues
+	FavoriteRepoIDs []int           `json:"favorite_repo_ids,omitempty"`
+}
+
+// BasketMessage is a Discord message collected for creating an issue from several messages.
+type BasketMessage struct {
+	Attachments []Attachment `json:"attachments,omitempty"`
+	AuthorID    string       `json:"author_id"`
+	AuthorName  string       `json:"author_name"`
+	ChannelID   string       `json:"channel_id"`
+	Content     string       `json:"content"`
+	GuildID     string       `json:"guild_id,omitempty"`
+	MessageID   string       `json:"message_id"`
+	Timestamp   time.Time    `json:"timestamp"`
+}
+
+// Attachment is a reference to a file attached to a Discord message.
+// The content of text attachments is only fetched when an issue is created.
+type Attachment struct {
+	ContentType string `json:"content_type,omitempty"`
+	Filename    string `json:"filename"`
+	Size        int    `json:"size,omitempty"`
+	URL         string `json:"url"`
+}
+
+// URL returns the link to the message on Discord.
+func (m BasketMessage) URL() string {
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", cmp.Or(m.GuildID, "@me"), m.ChannelID, m.MessageID)
+}
+
+// AddToBasket adds a message to the basket of the user, which is kept in the order the messages were posted.
+// Reports whether the message was added, i.e. was not in the basket already.
+func (u *User) AddToBasket(m BasketMessage) bool {
+	if slices.ContainsFunc(u.Basket, func(x BasketMessage) bool { return x.MessageID == m.MessageID }) {
+		return false
+	}
+	u.Basket = append(u.Basket, m)
+	slices.SortStableFunc(u.Basket, func(a, b BasketMessage) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	return true
+}
+
+// RemoveFromBasket removes messages from the basket of the user.
+// Messages added to the basket later are kept.
+func (u *User) RemoveFromBasket(messages []BasketMessage) {
+	u.Basket = slices.DeleteFunc(u.Basket, func(x BasketMessage) bool {
+		return slices.ContainsFunc(messages, func(m BasketMessage) bool { return m.MessageID == x.MessageID })
+	})
+}
+
+// IsBasketForGuild reports whether the basket is empty or has messages from the guild.
+// A basket only has messages from one guild or only from direct messages,
+// because permissions for creating the issue are checked for one guild.
+func (u *User) IsBasketForGuild(guildID string) bool {
+	return len(u.Basket) == 0 || u.Basket[0].GuildID == guildID
+}
+
+// IsFavorite reports whether a repo is a favorite of the user.
+func (u *User) IsFavorite(repoID int) bool {
+	return slices.Contains(u.FavoriteRepoIDs, repoID)
+}
+
+// ToggleFavorite adds a repo to the favorites of the user or removes it
+// and reports whether it is a favorite now.
+func (u *User) ToggleFavorite(repoID int) bool {
+	if u.IsFavorite(repoID) {
+		u.FavoriteRepoIDs = slices.DeleteFunc(u.FavoriteRepoIDs, func(id int) bool {
+			return id == repoID
+		})
+		return false
+	}
+	u.FavoriteRepoIDs = append(u.FavoriteRepoIDs, repoID)
+	return true
+}
+
+// Draft represents an unfinished issue which a user has saved to continue later.
+type Draft struct {
+	ID        int             `json:"id"`
+	Data      json.RawMessage `json:"data"` // state of the flow for creating the issue
+	RepoID    int             `json:"repo_id"`
+	Title     string          `json:"title"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	UserID    string          `json:"user_id"` // Discord user ID
+}
+
+// Session represents the state of an interaction flow, e.g. creating an issue.
+type Session struct {
+	ID        string          `json:"id"`
+	Data      json.RawMessage `json:"data"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// IsExpired reports whether a session has expired at the given time.
+func (s Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// Submission represents an issue which was submitted for review
+// and is waiting to be created.
+type Submission struct {
+	ID               int       `json:"id"`
+	AuthorID         string    `json:"author_id"`
+	Body             string    `json:"body"`
+	ChannelID        string    `json:"channel_id"`
+	CreatedAt        time.Time `json:"created_at"`
+	GuildID          string    `json:"guild_id"`
+	Labels           []string  `json:"labels"`
+	MessageID        string    `json:"message_id"`
+	MessageTimestamp time.Time `json:"message_timestamp"`
+	RepoID           int       `json:"repo_id"`
+	ThreadID         string    `json:"thread_id,omitempty"` // Discord thread the issue was submitted from
+	Title            string    `json:"title"`
+	UserID           string    `json:"user_id"` // Discord user ID of the user who submitted the issue
+}
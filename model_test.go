@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuildRules(t *testing.T) {
+	t.Run("should add rules with new IDs", func(t *testing.T) {
+		var g Guild
+		x1 := g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role1"})
+		x2 := g.AddRule(PermissionRule{Permission: permSubmitIssues, RoleID: "role1"})
+		assert.Equal(t, 1, x1.ID)
+		assert.Equal(t, 2, x2.ID)
+		assert.Len(t, g.Rules, 2)
+	})
+	t.Run("should not add duplicate rules", func(t *testing.T) {
+		var g Guild
+		x1 := g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role1"})
+		x2 := g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role1"})
+		assert.Equal(t, x1, x2)
+		assert.Len(t, g.Rules, 1)
+	})
+	t.Run("should remove rule", func(t *testing.T) {
+		var g Guild
+		x := g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role1"})
+		assert.True(t, g.RemoveRule(x.ID))
+		assert.False(t, g.RemoveRule(x.ID))
+		assert.Empty(t, g.Rules)
+	})
+	t.Run("should not reuse IDs of remaining rules", func(t *testing.T) {
+		var g Guild
+		g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role1"})
+		x2 := g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role2"})
+		g.RemoveRule(1)
+		x3 := g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role3"})
+		assert.Greater(t, x3.ID, x2.ID)
+	})
+}
+
+func TestGuildIsPermitted(t *testing.T) {
+	var g Guild
+	g.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role1"})
+	g.AddRule(PermissionRule{Permission: permManageRepos, RepoID: 7, UserID: "user1"})
+	cases := []struct {
+		name       string
+		permission Permission
+		repoID     int
+		userID     string
+		roleIDs    []string
+		want       bool
+	}{
+		{"role for all repos", permCreateIssues, 7, "user2", []string{"role1"}, true},
+		{"role for all repos checked for all repos", permCreateIssues, 0, "user2", []string{"role1"}, true},
+		{"other role", permCreateIssues, 7, "user2", []string{"role2"}, false},
+		{"user for repo", permManageRepos, 7, "user1", nil, true},
+		{"user for other repo", permManageRepos, 8, "user1", nil, false},
+		{"user for repo checked for all repos", permManageRepos, 0, "user1", nil, false},
+		{"other permission", permSubmitIssues, 7, "user1", []string{"role1"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := g.IsPermitted(tc.permission, tc.repoID, tc.userID, tc.roleIDs)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGuildChannelRepos(t *testing.T) {
+	t.Run("should return repo of first mapped channel", func(t *testing.T) {
+		var g Guild
+		g.SetChannelRepo("channel1", 1)
+		g.SetChannelRepo("category1", 2)
+		got, ok := g.ChannelRepo("channel1", "category1")
+		assert.True(t, ok)
+		assert.Equal(t, 1, got)
+	})
+	t.Run("should inherit repo from category", func(t *testing.T) {
+		var g Guild
+		g.SetChannelRepo("category1", 2)
+		got, ok := g.ChannelRepo("channel1", "category1")
+		assert.True(t, ok)
+		assert.Equal(t, 2, got)
+	})
+	t.Run("should report when no channel is mapped", func(t *testing.T) {
+		var g Guild
+		_, ok := g.ChannelRepo("channel1", "category1")
+		assert.False(t, ok)
+	})
+	t.Run("should remove mapping", func(t *testing.T) {
+		var g Guild
+		g.SetChannelRepo("channel1", 1)
+		g.SetChannelRepo("channel1", 0)
+		_, ok := g.ChannelRepo("channel1")
+		assert.False(t, ok)
+		assert.Empty(t, g.ChannelRepos)
+	})
+}
+
+func TestGuildForums(t *testing.T) {
+	t.Run("should set forum", func(t *testing.T) {
+		var g Guild
+		g.SetForum("forum1", Forum{RepoID: 1, RequireApproval: true})
+		assert.Equal(t, map[string]Forum{"forum1": {RepoID: 1, RequireApproval: true}}, g.Forums)
+	})
+	t.Run("should remove forum", func(t *testing.T) {
+		var g Guild
+		g.SetForum("forum1", Forum{RepoID: 1})
+		g.SetForum("forum1", Forum{})
+		assert.Empty(t, g.Forums)
+	})
+}
+
+func TestUserFavorites(t *testing.T) {
+	var u User
+	assert.True(t, u.ToggleFavorite(1))
+	assert.True(t, u.ToggleFavorite(2))
+	assert.True(t, u.IsFavorite(1))
+	assert.False(t, u.ToggleFavorite(1))
+	assert.False(t, u.IsFavorite(1))
+	assert.Equal(t, []int{2}, u.FavoriteRepoIDs)
+}
+
+func TestUserAddToBasket(t *testing.T) {
+	now := time.Now()
+	var u User
+	assert.True(t, u.AddToBasket(BasketMessage{MessageID: "2", Timestamp: now}))
+	assert.True(t, u.AddToBasket(BasketMessage{MessageID: "1", Timestamp: now.Add(-time.Hour)}))
+	assert.False(t, u.AddToBasket(BasketMessage{MessageID: "2", Timestamp: now}))
+	if assert.Len(t, u.Basket, 2) {
+		assert.Equal(t, "1", u.Basket[0].MessageID)
+		assert.Equal(t, "2", u.Basket[1].MessageID)
+	}
+}
+
+func TestUserRemoveFromBasket(t *testing.T) {
+	var u User
+	u.AddToBasket(BasketMessage{MessageID: "1"})
+	u.AddToBasket(BasketMessage{MessageID: "2"})
+	u.AddToBasket(BasketMessage{MessageID: "3"})
+	u.RemoveFromBasket([]BasketMessage{{MessageID: "1"}, {MessageID: "3"}, {MessageID: "4"}})
+	if assert.Len(t, u.Basket, 1) {
+		assert.Equal(t, "2", u.Basket[0].MessageID)
+	}
+}
+
+func TestUserIsBasketForGuild(t *testing.T) {
+	var u User
+	assert.True(t, u.IsBasketForGuild("guild1"))
+	u.AddToBasket(BasketMessage{GuildID: "guild1", MessageID: "1"})
+	assert.True(t, u.IsBasketForGuild("guild1"))
+	assert.False(t, u.IsBasketForGuild("guild2"))
+	assert.False(t, u.IsBasketForGuild("")) // direct messages
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// handleMessageReactionAdd starts creating an issue from a message in a DM
+// when a permitted member reacts with the configured emoji.
+func (b *Bot) handleMessageReactionAdd(mr *discordgo.MessageReactionAdd) error {
+	if !b.guildInstall || mr.GuildID == "" || mr.Member == nil || mr.Member.User == nil || mr.Member.User.Bot {
+		return nil
+	}
+	g, err := b.st.GetGuild(mr.GuildID)
+	if err != nil {
+		return err
+	}
+	if g.ReactionEmoji == "" || mr.Emoji.APIName() != g.ReactionEmoji {
+		return nil
+	}
+	member := *mr.Member
+	member.Permissions, err = b.memberChannelPermissions(&member, mr.ChannelID)
+	if err != nil {
+		slog.Warn("Failed to compute permissions for member", "userID", mr.UserID, "error", err)
+		member.Permissions = 0
+	}
+	shared, err := b.listPermittedGuildRepos(mr.GuildID, &member)
+	if err != nil {
+		return err
+	}
+	if len(shared) == 0 {
+		return nil // only members permitted to create issues with shared repos can use reactions
+	}
+	own, err := b.st.ListReposForUser(mr.UserID)
+	if err != nil {
+		return err
+	}
+	repos, u, err := b.sortReposForUser(mr.UserID, append(shared, own...))
+	if err != nil {
+		return err
+	}
+	m, err := b.ds.ChannelMessage(mr.ChannelID, mr.MessageID)
+	if err != nil {
+		return err
+	}
+	s := createIssueData{
+		attachments:      makeAttachments(m),
+		authorID:         m.Author.ID,
+		authorName:       m.Author.Username,
+		channelID:        mr.ChannelID,
+		guildID:          mr.GuildID,
+		member:           &member,
+		messageContent:   b.makeMessageContent(mr.GuildID, m, nil),
+		messageID:        m.ID,
+		messageTimestamp: m.Timestamp,
+		userID:           mr.UserID,
+	}
+	if c, err := b.channel(mr.ChannelID); err == nil && c.IsThread() {
+		s.threadID = c.ID
+	}
+	issues, err := b.st.ListIssuesForMessage(m.ID)
+	if err != nil {
+		return err
+	}
+	sessionID := b.newSessionID()
+	if err := b.sessions.Store(sessionID, s); err != nil {
+		return err
+	}
+	d := makeRepoSelectData(repos, u.FavoriteRepoIDs, sessionID, s)
+	content := fmt.Sprintf(
+		"%s from https://discord.com/channels/%s/%s/%s", d.Content, mr.GuildID, mr.ChannelID, mr.MessageID,
+	)
+	if len(issues) > 0 {
+		content += fmt.Sprintf("\n:warning: This message has already been turned into %d issue(s)", len(issues))
+	}
+	dm, err := b.ds.UserChannelCreate(mr.UserID)
+	if err != nil {
+		return err
+	}
+	_, err = b.ds.ChannelMessageSendComplex(dm.ID, &discordgo.MessageSend{
+		Content:    content,
+		Components: d.Components,
+	})
+	return err
+}
+
+// setReactionEmoji sets or removes the emoji for creating issues by reacting
+// from the options of a sub command.
+func (b *Bot) setReactionEmoji(ic *discordgo.InteractionCreate, sub *discordgo.ApplicationCommandInteractionDataOption) error {
+	if !b.isGuildInstalled(ic) {
+		return b.respondEphemeral(ic, ":x: Reactions require issuebot to be installed on this server")
+	}
+	ok, err := b.hasPermission(ic, permManageRepos, 0)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return b.respondEphemeral(ic, ":x: You are not permitted to change the reaction")
+	}
+	var emoji string
+	if o := sub.GetOption(optEmoji); o != nil {
+		var ok bool
+		emoji, ok = parseEmoji(o.StringValue())
+		if !ok {
+			return b.respondEphemeral(ic, ":x: Not an emoji: "+o.StringValue())
+		}
+	}
+	g, err := b.st.GetGuild(ic.GuildID)
+	if err != nil {
+		return err
+	}
+	g.ReactionEmoji = emoji
+	if err := b.st.UpdateGuild(g); err != nil {
+		return err
+	}
+	if emoji == "" {
+		return b.respondEphemeral(ic, ":white_check_mark: Reaction for creating issues removed")
+	}
+	return b.respondEphemeral(ic, fmt.Sprintf(
+		":white_check_mark: Reacting with %s to a message now starts creating an issue", displayEmoji(emoji),
+	))
+}
+
+var customEmojiRx = regexp.MustCompile(`^<a?:(\w+):(\d+)>$`)
+
+// memberChannelPermissions returns the permissions of a member in a channel.
+// The roles are taken from the member, because members are not cached without the GUILD_MEMBERS intent.
+// Threads have the permissions of their parent channel.
+func (b *Bot) memberChannelPermissions(member *discordgo.Member, channelID string) (int64, error) {
+	c, err := b.ds.State.Channel(channelID)
+	if err != nil {
+		return 0, err
+	}
+	if c.IsThread() {
+		channelID = c.ParentID
+	}
+	return b.ds.State.MessagePermissions(&discordgo.Message{
+		Author:    member.User,
+		ChannelID: channelID,
+		Member:    member,
+	})
+}
+
+// parseEmoji returns an emoji in the API format used by reactions and reports whether it is valid.
+// Custom emojis are given in message format, e.g. <:bug:123>.
+func parseEmoji(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if m := customEmojiRx.FindStringSubmatch(s); m != nil {
+		return m[1] + ":" + m[2], true
+	}
+	if s == "" || strings.ContainsAny(s, " :<>") || utf8.RuneCountInString(s) > 10 {
+		return "", false
+	}
+	if !strings.ContainsFunc(s, func(r rune) bool { return r > unicode.MaxASCII }) {
+		return "", false
+	}
+	return s, true
+}
+
+// displayEmoji returns an emoji in API format for display in a message.
+func displayEmoji(emoji string) string {
+	if strings.Contains(emoji, ":") {
+		return "<:" + emoji + ">"
+	}
+	return emoji
+}
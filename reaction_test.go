@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEmoji(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    string
+		isValid bool
+	}{
+		{"🐛", "🐛", true},
+		{" 🐛 ", "🐛", true},
+		{"<:bug:123>", "bug:123", true},
+		{"<a:bug:123>", "bug:123", true},
+		{"", "", false},
+		{"bug", "", false},
+		{":bug:", "", false},
+		{"🐛 🐛", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, ok := parseEmoji(tc.in)
+			assert.Equal(t, tc.isValid, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDisplayEmoji(t *testing.T) {
+	assert.Equal(t, "🐛", displayEmoji("🐛"))
+	assert.Equal(t, "<:bug:123>", displayEmoji("bug:123"))
+}
+
+func TestMemberChannelPermissions(t *testing.T) {
+	ds := &discordgo.Session{State: discordgo.NewState()}
+	err := ds.State.GuildAdd(&discordgo.Guild{
+		ID:      "guild1",
+		OwnerID: "owner",
+		Roles: []*discordgo.Role{
+			{ID: "guild1"},
+			{ID: "manager", Permissions: discordgo.PermissionManageGuild},
+		},
+		Channels: []*discordgo.Channel{
+			{ID: "channel1", GuildID: "guild1", Type: discordgo.ChannelTypeGuildText},
+		},
+		Threads: []*discordgo.Channel{
+			{ID: "thread1", GuildID: "guild1", ParentID: "channel1", Type: discordgo.ChannelTypeGuildPublicThread},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Bot{ds: ds}
+	cases := []struct {
+		name      string
+		roles     []string
+		channelID string
+		want      bool
+	}{
+		{"member with role in channel", []string{"manager"}, "channel1", true},
+		{"member with role in thread", []string{"manager"}, "thread1", true},
+		{"member without role", nil, "channel1", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// members are not in the state
+			member := &discordgo.Member{User: &discordgo.User{ID: "user1"}, Roles: tc.roles}
+			got, err := b.memberChannelPermissions(member, tc.channelID)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.want, got&discordgo.PermissionManageGuild != 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	sessionJanitorInterval = 10 * time.Minute
+	sessionTimeout         = time.Hour // how long a session is kept after its last use
+)
+
+var (
+	// errSessionExpired is returned when a session does not exist anymore, e.g. because it has expired.
+	errSessionExpired = errors.New("session expired")
+	// errSessionForbidden is returned when a session belongs to another user.
+	errSessionForbidden = errors.New("session belongs to another user")
+)
+
+// sessionStore stores the data of interaction flows like creating an issue.
+// Sessions are persisted, so flows survive restarts, and expire when not used for some time.
+type sessionStore struct {
+	st      *Storage
+	timeout time.Duration
+}
+
+func newSessionStore(st *Storage) *sessionStore {
+	return &sessionStore{st: st, timeout: sessionTimeout}
+}
+
+// Load returns the data of a session started by a user.
+// Returns [errSessionExpired] when the session does not exist
+// and [errSessionForbidden] when it was started by another user.
+func (ss *sessionStore) Load(id, userID string) (createIssueData, error) {
+	x, err := ss.st.GetSession(id)
+	if errors.Is(err, ErrNotFound) {
+		return createIssueData{}, errSessionExpired
+	} else if err != nil {
+		return createIssueData{}, err
+	}
+	var s createIssueData
+	if err := json.Unmarshal(x.Data, &s); err != nil {
+		return createIssueData{}, err
+	}
+	if s.userID != userID {
+		return createIssueData{}, errSessionForbidden
+	}
+	return s, nil
+}
+
+// Store creates or updates a session and extends its expiry.
+func (ss *sessionStore) Store(id string, s createIssueData) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ss.st.UpdateSession(&Session{
+		ID:        id,
+		Data:      data,
+		ExpiresAt: time.Now().Add(ss.timeout),
+	})
+}
+
+// Take returns the data of a session started by a user and deletes the session,
+// so that it can only be used once, e.g. when clicking a button twice.
+// Returns the same errors as [sessionStore.Load].
+func (ss *sessionStore) Take(id, userID string) (createIssueData, error) {
+	s, err := ss.Load(id, userID)
+	if err != nil {
+		return createIssueData{}, err
+	}
+	if err := ss.st.ClaimSession(id); errors.Is(err, ErrNotFound) {
+		return createIssueData{}, errSessionExpired
+	} else if err != nil {
+		return createIssueData{}, err
+	}
+	return s, nil
+}
+
+// Delete deletes a session.
+func (ss *sessionStore) Delete(id string) error {
+	return ss.st.DeleteSession(id)
+}
+
+// StartSessionJanitor starts deleting expired sessions in the background until the context is cancelled.
+func (b *Bot) StartSessionJanitor(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(sessionJanitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			n, err := b.st.DeleteExpiredSessions(time.Now())
+			if err != nil {
+				slog.Error("Failed to delete expired sessions", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("Expired sessions deleted", "count", n)
+			}
+		}
+	}()
+}
+
+// createIssueDataJSON is the persisted form of [createIssueData].
+type createIssueDataJSON struct {
+	Attachments      []Attachment      `json:"attachments,omitempty"`
+	AuthorID         string            `json:"author_id,omitempty"`
+	AuthorName       string            `json:"author_name,omitempty"`
+	Basket           []BasketMessage   `json:"basket,omitempty"`
+	ChannelID        string            `json:"channel_id,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	DraftID          int               `json:"draft_id,omitempty"`
+	GuildID          string            `json:"guild_id,omitempty"`
+	HasPreview       bool              `json:"has_preview,omitempty"`
+	IssueType        issueType         `json:"issue_type,omitempty"`
+	Member           *discordgo.Member `json:"member,omitempty"`
+	MessageContent   string            `json:"message_content,omitempty"`
+	MessageID        string            `json:"message_id,omitempty"`
+	MessageTimestamp time.Time         `json:"message_timestamp,omitzero"`
+	RepoID           int               `json:"repo_id,omitempty"`
+	RepoPage         int               `json:"repo_page,omitempty"`
+	RepoSearch       string            `json:"repo_search,omitempty"`
+	ThreadID         string            `json:"thread_id,omitempty"`
+	Title            string            `json:"title,omitempty"`
+	UserID           string            `json:"user_id"`
+}
+
+func (s createIssueData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(createIssueDataJSON{
+		Attachments:      s.attachments,
+		AuthorID:         s.authorID,
+		AuthorName:       s.authorName,
+		Basket:           s.basket,
+		ChannelID:        s.channelID,
+		Description:      s.description,
+		DraftID:          s.draftID,
+		GuildID:          s.guildID,
+		HasPreview:       s.hasPreview,
+		IssueType:        s.issueType,
+		Member:           s.member,
+		MessageContent:   s.messageContent,
+		MessageID:        s.messageID,
+		MessageTimestamp: s.messageTimestamp,
+		RepoID:           s.repoID,
+		RepoPage:         s.repoPage,
+		RepoSearch:       s.repoSearch,
+		ThreadID:         s.threadID,
+		Title:            s.title,
+		UserID:           s.userID,
+	})
+}
+
+func (s *createIssueData) UnmarshalJSON(data []byte) error {
+	var x createIssueDataJSON
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	*s = createIssueData{
+		attachments:      x.Attachments,
+		authorID:         x.AuthorID,
+		authorName:       x.AuthorName,
+		basket:           x.Basket,
+		channelID:        x.ChannelID,
+		description:      x.Description,
+		draftID:          x.DraftID,
+		guildID:          x.GuildID,
+		hasPreview:       x.HasPreview,
+		issueType:        x.IssueType,
+		member:           x.Member,
+		messageContent:   x.MessageContent,
+		messageID:        x.MessageID,
+		messageTimestamp: x.MessageTimestamp,
+		repoID:           x.RepoID,
+		repoPage:         x.RepoPage,
+		repoSearch:       x.RepoSearch,
+		threadID:         x.ThreadID,
+		title:            x.Title,
+		userID:           x.UserID,
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSessionStore(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(p, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %s", err)
+	}
+	defer db.Close()
+	st := NewStorage(db)
+	if err = st.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("can store and load a session", func(t *testing.T) {
+		ss := newSessionStore(st)
+		s1 := createIssueData{
+			attachments:      []Attachment{{Filename: "log.txt", URL: "https://cdn.discordapp.com/log.txt"}},
+			authorID:         "author",
+			issueType:        featureRequest,
+			member:           &discordgo.Member{Roles: []string{"role1"}},
+			messageTimestamp: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+			repoID:           42,
+			title:            "title",
+			userID:           "user1",
+		}
+		if assert.NoError(t, ss.Store("1", s1)) {
+			s2, err := ss.Load("1", "user1")
+			if assert.NoError(t, err) {
+				assert.Equal(t, s1, s2)
+			}
+		}
+	})
+	t.Run("should return expired for deleted session", func(t *testing.T) {
+		ss := newSessionStore(st)
+		if err := ss.Store("2", createIssueData{userID: "user1"}); err != nil {
+			t.Fatal(err)
+		}
+		if assert.NoError(t, ss.Delete("2")) {
+			_, err := ss.Load("2", "user1")
+			assert.ErrorIs(t, err, errSessionExpired)
+		}
+	})
+	t.Run("should return expired for timed out session", func(t *testing.T) {
+		ss := newSessionStore(st)
+		ss.timeout = -time.Second
+		if err := ss.Store("3", createIssueData{userID: "user1"}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ss.Load("3", "user1")
+		assert.ErrorIs(t, err, errSessionExpired)
+	})
+	t.Run("can take a session only once", func(t *testing.T) {
+		ss := newSessionStore(st)
+		if err := ss.Store("5", createIssueData{userID: "user1"}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ss.Take("5", "user2")
+		assert.ErrorIs(t, err, errSessionForbidden)
+		s, err := ss.Take("5", "user1")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "user1", s.userID)
+		}
+		_, err = ss.Take("5", "user1")
+		assert.ErrorIs(t, err, errSessionExpired)
+	})
+	t.Run("should not load session of another user", func(t *testing.T) {
+		ss := newSessionStore(st)
+		if err := ss.Store("4", createIssueData{userID: "user1"}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ss.Load("4", "user2")
+		assert.ErrorIs(t, err, errSessionForbidden)
+	})
+}
+
+func TestNewSessionID(t *testing.T) {
+	var b Bot
+	id1 := b.newSessionID()
+	id2 := b.newSessionID()
+	assert.NotEqual(t, id1, id2)
+	assert.GreaterOrEqual(t, len(id1), 20)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,13 +10,24 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
 const (
-	bucketRepos       = "repos"
-	bucketReposIndex1 = "reposIndex1"
+	bucketDrafts             = "drafts"
+	bucketDraftsIndexUser    = "draftsIndexUser"
+	bucketGuilds             = "guilds"
+	bucketIssues             = "issues"
+	bucketIssuesIndexMessage = "issuesIndexMessage"
+	bucketIssuesIndexThread  = "issuesIndexThread"
+	bucketIssuesIndexUser    = "issuesIndexUser"
+	bucketRepos              = "repos"
+	bucketReposIndex1        = "reposIndex1"
+	bucketSessions           = "sessions"
+	bucketSubmissions        = "submissions"
+	bucketUsers              = "users"
 )
 
 var ErrNotFound = errors.New("not found")
@@ -34,13 +46,24 @@ func NewStorage(db *bolt.DB) *Storage {
 // Init creates all required buckets and deletes obsolete buckets.
 func (st *Storage) Init() error {
 	err := st.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(bucketRepos))
-		if err != nil {
-			return err
-		}
-		_, err = tx.CreateBucketIfNotExists([]byte(bucketReposIndex1))
-		if err != nil {
-			return err
+		for _, name := range []string{
+			bucketDrafts,
+			bucketDraftsIndexUser,
+			bucketGuilds,
+			bucketIssues,
+			bucketIssuesIndexMessage,
+			bucketIssuesIndexThread,
+			bucketIssuesIndexUser,
+			bucketRepos,
+			bucketReposIndex1,
+			bucketSessions,
+			bucketSubmissions,
+			bucketUsers,
+		} {
+			_, err := tx.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return err
+			}
 		}
 		return nil
 	})
@@ -58,7 +81,7 @@ func (st *Storage) CountReposForUser(userID string) (int, error) {
 	return len(repos), nil
 }
 
-// DeleteAll deletes all repos.
+// DeleteAll deletes all repos, issues, drafts, guilds, sessions, submissions and users.
 // This method is mainly intended for tests.
 func (st *Storage) DeleteAll() error {
 	err := st.db.Update(func(tx *bolt.Tx) error {
@@ -79,7 +102,29 @@ func (st *Storage) DeleteAll() error {
 			}
 			return nil
 		})
-		return err
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{
+			bucketDrafts,
+			bucketDraftsIndexUser,
+			bucketGuilds,
+			bucketIssues,
+			bucketIssuesIndexMessage,
+			bucketIssuesIndexThread,
+			bucketIssuesIndexUser,
+			bucketSessions,
+			bucketSubmissions,
+			bucketUsers,
+		} {
+			if err := tx.DeleteBucket([]byte(name)); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 	if err != nil {
 		return fmt.Errorf("DeleteAll: %w", err)
@@ -189,6 +234,7 @@ func (st *Storage) ListAllRepos() ([]*Repo, error) {
 }
 
 // ListReposForUser returns the repos of a user ordered by repo name.
+// Shared repos are not included.
 func (st *Storage) ListReposForUser(userID string) ([]*Repo, error) {
 	wrapErr := func(err error) error {
 		return fmt.Errorf("ListReposForUser: %s: %w", userID, err)
@@ -204,7 +250,42 @@ func (st *Storage) ListReposForUser(userID string) ([]*Repo, error) {
 			if err := json.Unmarshal(data, &r); err != nil {
 				return err
 			}
-			if r.UserID != userID {
+			if r.UserID != userID || r.IsShared() {
+				return nil
+			}
+			repos = append(repos, r)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	if len(repos) > 0 {
+		slices.SortFunc(repos, func(a, b *Repo) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+	}
+	return repos, nil
+}
+
+// ListReposForGuild returns the shared repos of a guild ordered by repo name.
+func (st *Storage) ListReposForGuild(guildID string) ([]*Repo, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("ListReposForGuild: %s: %w", guildID, err)
+	}
+	if guildID == "" {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	repos := make([]*Repo, 0)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketRepos))
+		err := b.ForEach(func(_, data []byte) error {
+			r := new(Repo)
+			if err := json.Unmarshal(data, &r); err != nil {
+				return err
+			}
+			if r.GuildID != guildID {
 				return nil
 			}
 			repos = append(repos, r)
@@ -224,11 +305,12 @@ func (st *Storage) ListReposForUser(userID string) ([]*Repo, error) {
 }
 
 type UpdateOrCreateRepoParams struct {
-	Repo   string
-	Owner  string
-	Token  string
-	UserID string
-	Vendor Vendor
+	GuildID string // shares the repo with a guild when set
+	Repo    string
+	Owner   string
+	Token   string
+	UserID  string
+	Vendor  Vendor
 }
 
 func (st *Storage) UpdateOrCreateRepo(arg UpdateOrCreateRepoParams) (*Repo, bool, error) {
@@ -239,17 +321,23 @@ func (st *Storage) UpdateOrCreateRepo(arg UpdateOrCreateRepoParams) (*Repo, bool
 		return nil, false, wrapErr(ErrInvalidArguments)
 	}
 	r := &Repo{
-		Repo:   arg.Repo,
-		Owner:  arg.Owner,
-		Token:  arg.Token,
-		UserID: arg.UserID,
-		Vendor: arg.Vendor,
+		GuildID: arg.GuildID,
+		Repo:    arg.Repo,
+		Owner:   arg.Owner,
+		Token:   arg.Token,
+		UserID:  arg.UserID,
+		Vendor:  arg.Vendor,
 	}
 	var created bool
 	err := st.db.Update(func(tx *bolt.Tx) error {
 		repos := tx.Bucket([]byte(bucketRepos))
 		index := tx.Bucket([]byte(bucketReposIndex1))
-		uniqueID := makeUniqueID(arg.UserID, arg.Vendor.String(), arg.Owner, arg.Repo)
+		var uniqueID []byte
+		if arg.GuildID != "" {
+			uniqueID = makeUniqueID("guild:"+arg.GuildID, arg.Vendor.String(), arg.Owner, arg.Repo)
+		} else {
+			uniqueID = makeUniqueID(arg.UserID, arg.Vendor.String(), arg.Owner, arg.Repo)
+		}
 		bid := index.Get([]byte(uniqueID))
 		if bid == nil {
 			id, _ := repos.NextSequence()
@@ -260,6 +348,20 @@ func (st *Storage) UpdateOrCreateRepo(arg UpdateOrCreateRepoParams) (*Repo, bool
 				return err
 			}
 			created = true
+		} else {
+			id, err := strconv.Atoi(string(bid))
+			if err != nil {
+				return err
+			}
+			r.ID = id
+			if data := repos.Get(bid); data != nil {
+				var old Repo
+				if err := json.Unmarshal(data, &old); err != nil {
+					return err
+				}
+				r.Templates = old.Templates // keep settings when the token is updated
+				r.UploadLongBodies = old.UploadLongBodies
+			}
 		}
 		data, err := json.Marshal(r)
 		if err != nil {
@@ -275,6 +377,766 @@ func (st *Storage) UpdateOrCreateRepo(arg UpdateOrCreateRepoParams) (*Repo, bool
 	return r, created, err
 }
 
+// UpdateRepo updates an existing repo.
+// Returns [ErrNotFound] when the repo does not exist.
+func (st *Storage) UpdateRepo(r *Repo) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("UpdateRepo: %d: %w", r.ID, err)
+	}
+	if r.ID == 0 || !r.isValid() {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		repos := tx.Bucket([]byte(bucketRepos))
+		bid := itob(r.ID)
+		if repos.Get(bid) == nil {
+			return ErrNotFound
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return repos.Put(bid, data)
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	slog.Info("Repo updated", "id", r.ID)
+	return nil
+}
+
+// GetGuild returns the configuration of a guild.
+// Returns an empty configuration when the guild has not been configured yet.
+func (st *Storage) GetGuild(guildID string) (*Guild, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("GetGuild: %s: %w", guildID, err)
+	}
+	if guildID == "" {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	g := &Guild{ID: guildID}
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketGuilds))
+		data := b.Get([]byte(guildID))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, g)
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	g.migrateReporterRoles()
+	return g, nil
+}
+
+// UpdateGuild stores the configuration of a guild.
+func (st *Storage) UpdateGuild(g *Guild) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("UpdateGuild: %+v: %w", g, err)
+	}
+	if g == nil || g.ID == "" {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketGuilds))
+		data, err := json.Marshal(g)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(g.ID), data)
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	slog.Info("Guild updated", "id", g.ID)
+	return nil
+}
+
+type CreateIssueParams struct {
+	AuthorID         string
+	ChannelID        string
+	GuildID          string
+	MessageID        string
+	MessageTimestamp time.Time
+	Number           int
+	RepoID           int
+	ThreadID         string
+	Title            string
+	URL              string
+	UserID           string
+}
+
+// CreateIssue stores a new issue and returns it.
+func (st *Storage) CreateIssue(arg CreateIssueParams) (*Issue, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("CreateIssue: %+v: %w", arg, err)
+	}
+	if arg.RepoID == 0 || arg.UserID == "" || arg.URL == "" {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	it := &Issue{
+		AuthorID:         arg.AuthorID,
+		ChannelID:        arg.ChannelID,
+		CreatedAt:        time.Now().UTC(),
+		GuildID:          arg.GuildID,
+		MessageID:        arg.MessageID,
+		MessageTimestamp: arg.MessageTimestamp,
+		Number:           arg.Number,
+		RepoID:           arg.RepoID,
+		ThreadID:         arg.ThreadID,
+		Title:            arg.Title,
+		URL:              arg.URL,
+		UserID:           arg.UserID,
+	}
+	if it.ThreadID != "" {
+		// comment sync starts with the creation of the issue
+		it.CommentsSyncedAt = it.CreatedAt
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		issues := tx.Bucket([]byte(bucketIssues))
+		id, err := issues.NextSequence()
+		if err != nil {
+			return err
+		}
+		it.ID = int(id)
+		bid := itob(it.ID)
+		data, err := json.Marshal(it)
+		if err != nil {
+			return err
+		}
+		if err := issues.Put(bid, data); err != nil {
+			return err
+		}
+		indexUser := tx.Bucket([]byte(bucketIssuesIndexUser))
+		if err := indexUser.Put(makeIndexKey(it.UserID, it.ID), bid); err != nil {
+			return err
+		}
+		if it.MessageID != "" {
+			indexMessage := tx.Bucket([]byte(bucketIssuesIndexMessage))
+			if err := indexMessage.Put(makeIndexKey(it.MessageID, it.ID), bid); err != nil {
+				return err
+			}
+		}
+		if it.ThreadID != "" {
+			indexThread := tx.Bucket([]byte(bucketIssuesIndexThread))
+			if err := indexThread.Put(makeIndexKey(it.ThreadID, it.ID), bid); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	slog.Info("Issue stored", "id", it.ID, "repoID", it.RepoID, "url", it.URL)
+	return it, nil
+}
+
+func (st *Storage) GetIssue(id int) (*Issue, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("GetIssue: %d: %w", id, err)
+	}
+	if id == 0 {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	it := new(Issue)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketIssues))
+		data := b.Get(itob(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, it)
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return it, nil
+}
+
+// UpdateIssue updates an existing issue.
+// The indexed fields of an issue must not be changed.
+func (st *Storage) UpdateIssue(it *Issue) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("UpdateIssue: %+v: %w", it, err)
+	}
+	if it == nil || it.ID == 0 {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketIssues))
+		bid := itob(it.ID)
+		if b.Get(bid) == nil {
+			return ErrNotFound
+		}
+		data, err := json.Marshal(it)
+		if err != nil {
+			return err
+		}
+		return b.Put(bid, data)
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// ListIssuesForMessage returns the issues created from a Discord message ordered by creation.
+func (st *Storage) ListIssuesForMessage(messageID string) ([]*Issue, error) {
+	issues, err := st.listIssuesByIndex(bucketIssuesIndexMessage, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("ListIssuesForMessage: %s: %w", messageID, err)
+	}
+	return issues, nil
+}
+
+// ListIssuesForThread returns the issues created from a Discord thread ordered by creation.
+func (st *Storage) ListIssuesForThread(threadID string) ([]*Issue, error) {
+	issues, err := st.listIssuesByIndex(bucketIssuesIndexThread, threadID)
+	if err != nil {
+		return nil, fmt.Errorf("ListIssuesForThread: %s: %w", threadID, err)
+	}
+	return issues, nil
+}
+
+// ListSyncedIssues returns all issues which sync their comments with a thread ordered by ID.
+func (st *Storage) ListSyncedIssues() ([]*Issue, error) {
+	issues := make([]*Issue, 0)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketIssues))
+		return tx.Bucket([]byte(bucketIssuesIndexThread)).ForEach(func(k, v []byte) error {
+			data := b.Get(v)
+			if data == nil {
+				return nil
+			}
+			it := new(Issue)
+			if err := json.Unmarshal(data, it); err != nil {
+				return err
+			}
+			if !it.SyncStopped {
+				issues = append(issues, it)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ListSyncedIssues: %w", err)
+	}
+	slices.SortFunc(issues, func(a, b *Issue) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return issues, nil
+}
+
+// ListIssuesForUser returns the issues created by a user with the newest first.
+func (st *Storage) ListIssuesForUser(userID string) ([]*Issue, error) {
+	issues, err := st.listIssuesByIndex(bucketIssuesIndexUser, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ListIssuesForUser: %s: %w", userID, err)
+	}
+	slices.Reverse(issues)
+	return issues, nil
+}
+
+// listIssuesByIndex returns all issues stored under a key in an index ordered by ID.
+func (st *Storage) listIssuesByIndex(bucket, key string) ([]*Issue, error) {
+	if key == "" {
+		return nil, ErrInvalidArguments
+	}
+	issues := make([]*Issue, 0)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketIssues))
+		c := tx.Bucket([]byte(bucket)).Cursor()
+		prefix := []byte(key + "-")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			data := b.Get(v)
+			if data == nil {
+				continue
+			}
+			it := new(Issue)
+			if err := json.Unmarshal(data, it); err != nil {
+				return err
+			}
+			issues = append(issues, it)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(issues, func(a, b *Issue) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return issues, nil
+}
+
+type CreateSubmissionParams struct {
+	AuthorID         string
+	Body             string
+	ChannelID        string
+	GuildID          string
+	Labels           []string
+	MessageID        string
+	MessageTimestamp time.Time
+	RepoID           int
+	ThreadID         string
+	Title            string
+	UserID           string
+}
+
+// CreateSubmission stores a new submission and returns it.
+func (st *Storage) CreateSubmission(arg CreateSubmissionParams) (*Submission, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("CreateSubmission: %+v: %w", arg, err)
+	}
+	if arg.RepoID == 0 || arg.UserID == "" || arg.GuildID == "" || arg.Title == "" {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	x := &Submission{
+		AuthorID:         arg.AuthorID,
+		Body:             arg.Body,
+		ChannelID:        arg.ChannelID,
+		CreatedAt:        time.Now().UTC(),
+		GuildID:          arg.GuildID,
+		Labels:           arg.Labels,
+		MessageID:        arg.MessageID,
+		MessageTimestamp: arg.MessageTimestamp,
+		RepoID:           arg.RepoID,
+		ThreadID:         arg.ThreadID,
+		Title:            arg.Title,
+		UserID:           arg.UserID,
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSubmissions))
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		x.ID = int(id)
+		data, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(x.ID), data)
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	slog.Info("Submission stored", "id", x.ID, "repoID", x.RepoID)
+	return x, nil
+}
+
+func (st *Storage) DeleteSubmission(id int) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("DeleteSubmission: %d: %w", id, err)
+	}
+	if id == 0 {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSubmissions))
+		return b.Delete(itob(id))
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	slog.Info("Submission deleted", "id", id)
+	return nil
+}
+
+// ClaimSubmission removes a submission and returns it.
+// Only one caller can claim a submission, all others get [ErrNotFound].
+func (st *Storage) ClaimSubmission(id int) (*Submission, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("ClaimSubmission: %d: %w", id, err)
+	}
+	if id == 0 {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	x := new(Submission)
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSubmissions))
+		data := b.Get(itob(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(data, x); err != nil {
+			return err
+		}
+		return b.Delete(itob(id))
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	slog.Info("Submission claimed", "id", id)
+	return x, nil
+}
+
+// RestoreSubmission stores a claimed submission again, e.g. when creating its issue failed.
+func (st *Storage) RestoreSubmission(x *Submission) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("RestoreSubmission: %+v: %w", x, err)
+	}
+	if x == nil || x.ID == 0 {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSubmissions))
+		data, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(x.ID), data)
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	slog.Info("Submission restored", "id", x.ID)
+	return nil
+}
+
+func (st *Storage) GetSubmission(id int) (*Submission, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("GetSubmission: %d: %w", id, err)
+	}
+	if id == 0 {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	x := new(Submission)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSubmissions))
+		data := b.Get(itob(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, x)
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return x, nil
+}
+
+// GetUser returns the settings of a user.
+// Returns empty settings when the user has none yet.
+func (st *Storage) GetUser(userID string) (*User, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("GetUser: %s: %w", userID, err)
+	}
+	if userID == "" {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	u := &User{ID: userID}
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketUsers))
+		data := b.Get([]byte(userID))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, u)
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return u, nil
+}
+
+// UpdateUser stores the settings of a user.
+func (st *Storage) UpdateUser(u *User) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("UpdateUser: %+v: %w", u, err)
+	}
+	if u == nil || u.ID == "" {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketUsers))
+		data, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(u.ID), data)
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// CreateDraft stores a new draft and returns it.
+func (st *Storage) CreateDraft(userID string, repoID int, title string, data []byte) (*Draft, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("CreateDraft: %s: %w", userID, err)
+	}
+	if userID == "" || len(data) == 0 {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	x := &Draft{
+		Data:      data,
+		RepoID:    repoID,
+		Title:     title,
+		UpdatedAt: time.Now().UTC(),
+		UserID:    userID,
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketDrafts))
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		x.ID = int(id)
+		data, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(itob(x.ID), data); err != nil {
+			return err
+		}
+		index := tx.Bucket([]byte(bucketDraftsIndexUser))
+		return index.Put(makeIndexKey(userID, x.ID), itob(x.ID))
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return x, nil
+}
+
+// UpdateDraft updates an existing draft.
+// Returns [ErrNotFound] when the draft does not exist.
+func (st *Storage) UpdateDraft(d *Draft) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("UpdateDraft: %d: %w", d.ID, err)
+	}
+	if d.ID == 0 || d.UserID == "" || len(d.Data) == 0 {
+		return wrapErr(ErrInvalidArguments)
+	}
+	d.UpdatedAt = time.Now().UTC()
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketDrafts))
+		if b.Get(itob(d.ID)) == nil {
+			return ErrNotFound
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(d.ID), data)
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// GetDraft returns a draft.
+func (st *Storage) GetDraft(id int) (*Draft, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("GetDraft: %d: %w", id, err)
+	}
+	if id == 0 {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	x := new(Draft)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(bucketDrafts)).Get(itob(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, x)
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return x, nil
+}
+
+// ListDraftsForUser returns the drafts of a user with the most recently updated first.
+func (st *Storage) ListDraftsForUser(userID string) ([]*Draft, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("ListDraftsForUser: %s: %w", userID, err)
+	}
+	if userID == "" {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	drafts := make([]*Draft, 0)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketDrafts))
+		c := tx.Bucket([]byte(bucketDraftsIndexUser)).Cursor()
+		prefix := []byte(userID + "-")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			data := b.Get(v)
+			if data == nil {
+				continue
+			}
+			x := new(Draft)
+			if err := json.Unmarshal(data, x); err != nil {
+				return err
+			}
+			drafts = append(drafts, x)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	slices.SortFunc(drafts, func(a, b *Draft) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return drafts, nil
+}
+
+// DeleteDraft deletes a draft.
+func (st *Storage) DeleteDraft(id int) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("DeleteDraft: %d: %w", id, err)
+	}
+	if id == 0 {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketDrafts))
+		data := b.Get(itob(id))
+		if data == nil {
+			return nil
+		}
+		var x Draft
+		if err := json.Unmarshal(data, &x); err != nil {
+			return err
+		}
+		if err := tx.Bucket([]byte(bucketDraftsIndexUser)).Delete(makeIndexKey(x.UserID, id)); err != nil {
+			return err
+		}
+		return b.Delete(itob(id))
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// GetSession returns a session.
+// Returns [ErrNotFound] when the session does not exist or has expired.
+func (st *Storage) GetSession(id string) (*Session, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("GetSession: %s: %w", id, err)
+	}
+	if id == "" {
+		return nil, wrapErr(ErrInvalidArguments)
+	}
+	x := new(Session)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSessions))
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, x)
+	})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	if x.IsExpired(time.Now()) {
+		return nil, wrapErr(ErrNotFound)
+	}
+	return x, nil
+}
+
+// UpdateSession creates or updates a session.
+func (st *Storage) UpdateSession(s *Session) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("UpdateSession: %s: %w", s.ID, err)
+	}
+	if s.ID == "" || s.ExpiresAt.IsZero() {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSessions))
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(s.ID), data)
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// DeleteSession deletes a session. Deleting a session which does not exist is not an error.
+func (st *Storage) DeleteSession(id string) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("DeleteSession: %s: %w", id, err)
+	}
+	if id == "" {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketSessions)).Delete([]byte(id))
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// ClaimSession deletes a session and returns [ErrNotFound] when it did not exist.
+// Only one caller can claim a session, which prevents it from being used twice.
+func (st *Storage) ClaimSession(id string) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("ClaimSession: %s: %w", id, err)
+	}
+	if id == "" {
+		return wrapErr(ErrInvalidArguments)
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSessions))
+		if b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions deletes all sessions which have expired at the given time
+// and returns how many were deleted.
+func (st *Storage) DeleteExpiredSessions(now time.Time) (int, error) {
+	var n int
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSessions))
+		var ids [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var x Session
+			if err := json.Unmarshal(v, &x); err != nil {
+				return err
+			}
+			if x.IsExpired(now) {
+				ids = append(ids, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := b.Delete(id); err != nil {
+				return err
+			}
+		}
+		n = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("DeleteExpiredSessions: %w", err)
+	}
+	return n, nil
+}
+
 // itob returns the byte representation of an integer.
 func itob(v int) []byte {
 	return []byte(strconv.Itoa(v))
@@ -283,3 +1145,8 @@ func itob(v int) []byte {
 func makeUniqueID(userID, vendor, owner, repo string) []byte {
 	return fmt.Appendf(nil, "%s-%s-%s-%s", userID, vendor, owner, repo)
 }
+
+// makeIndexKey returns the key for an entry in a one-to-many index.
+func makeIndexKey(key string, id int) []byte {
+	return fmt.Appendf(nil, "%s-%d", key, id)
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"math/rand/v2"
 	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/icrowley/fake"
 	"github.com/stretchr/testify/assert"
@@ -63,6 +65,48 @@ func TestStorage(t *testing.T) {
 			assert.Equal(t, "token", r1.Token)
 		}
 	})
+	t.Run("can update templates of a repo", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		r1 := createRepo(t, st)
+		r1.Templates = IssueTemplates{Title: "[Discord] {{.Title}}"}
+		if err := st.UpdateRepo(r1); err != nil {
+			t.Fatal(err)
+		}
+		r2, err := st.GetRepo(r1.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, r1, r2)
+		}
+	})
+	t.Run("should return not found when updating unknown repo", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		err := st.UpdateRepo(&Repo{ID: 42, Owner: "owner", Repo: "repo", Token: "token", UserID: "user", Vendor: gitHub})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("should keep templates when updating the token of a repo", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		r1 := createRepo(t, st)
+		r1.Templates = IssueTemplates{Body: "{{.MessageContent}}"}
+		if err := st.UpdateRepo(r1); err != nil {
+			t.Fatal(err)
+		}
+		r2, _, err := st.UpdateOrCreateRepo(UpdateOrCreateRepoParams{
+			Owner:  r1.Owner,
+			Repo:   r1.Repo,
+			UserID: r1.UserID,
+			Token:  "new-token",
+			Vendor: r1.Vendor,
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "new-token", r2.Token)
+			assert.Equal(t, r1.Templates, r2.Templates)
+		}
+	})
 	t.Run("can get a repo", func(t *testing.T) {
 		if err := st.DeleteAll(); err != nil {
 			t.Fatal(err)
@@ -165,6 +209,383 @@ func TestStorage(t *testing.T) {
 			}
 		}
 	})
+	t.Run("can create and get an issue", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		r := createRepo(t, st)
+		it1, err := st.CreateIssue(CreateIssueParams{
+			AuthorID:  "author",
+			ChannelID: "channel",
+			GuildID:   "guild",
+			MessageID: "message",
+			Number:    42,
+			RepoID:    r.ID,
+			Title:     "title",
+			URL:       "url",
+			UserID:    "user",
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, 42, it1.Number)
+			assert.Equal(t, r.ID, it1.RepoID)
+			assert.False(t, it1.CreatedAt.IsZero())
+			it2, err := st.GetIssue(it1.ID)
+			if assert.NoError(t, err) {
+				assert.Equal(t, it1.ID, it2.ID)
+				assert.Equal(t, it1.URL, it2.URL)
+				assert.Equal(t, it1.MessageID, it2.MessageID)
+			}
+		}
+	})
+	t.Run("can list issues for user with newest first", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		it1 := createIssue(t, st, CreateIssueParams{UserID: "user1"})
+		it2 := createIssue(t, st, CreateIssueParams{UserID: "user1"})
+		createIssue(t, st, CreateIssueParams{UserID: "user11"})
+		xx, err := st.ListIssuesForUser("user1")
+		if assert.NoError(t, err) {
+			var got []int
+			for _, x := range xx {
+				got = append(got, x.ID)
+			}
+			assert.Equal(t, []int{it2.ID, it1.ID}, got)
+		}
+	})
+	t.Run("can list issues for message", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		it1 := createIssue(t, st, CreateIssueParams{MessageID: "message1"})
+		it2 := createIssue(t, st, CreateIssueParams{MessageID: "message1"})
+		createIssue(t, st, CreateIssueParams{MessageID: "message2"})
+		xx, err := st.ListIssuesForMessage("message1")
+		if assert.NoError(t, err) {
+			var got []int
+			for _, x := range xx {
+				got = append(got, x.ID)
+			}
+			assert.Equal(t, []int{it1.ID, it2.ID}, got)
+		}
+	})
+	t.Run("can list issues for thread", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		it1 := createIssue(t, st, CreateIssueParams{ThreadID: "thread1"})
+		createIssue(t, st, CreateIssueParams{ThreadID: "thread2"})
+		createIssue(t, st)
+		xx, err := st.ListIssuesForThread("thread1")
+		if assert.NoError(t, err) {
+			var got []int
+			for _, x := range xx {
+				got = append(got, x.ID)
+			}
+			assert.Equal(t, []int{it1.ID}, got)
+		}
+	})
+	t.Run("can update issue", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		it := createIssue(t, st, CreateIssueParams{ThreadID: "thread1"})
+		it.LastCommentID = 42
+		it.SyncStopped = true
+		if err := st.UpdateIssue(it); err != nil {
+			t.Fatal(err)
+		}
+		got, err := st.GetIssue(it.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 42, got.LastCommentID)
+			assert.True(t, got.SyncStopped)
+		}
+	})
+	t.Run("should return not found when updating unknown issue", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		err := st.UpdateIssue(&Issue{ID: 42})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("can list synced issues", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		it1 := createIssue(t, st, CreateIssueParams{ThreadID: "thread1"})
+		it2 := createIssue(t, st, CreateIssueParams{ThreadID: "thread2"})
+		it2.SyncStopped = true
+		if err := st.UpdateIssue(it2); err != nil {
+			t.Fatal(err)
+		}
+		createIssue(t, st)
+		xx, err := st.ListSyncedIssues()
+		if assert.NoError(t, err) {
+			var got []int
+			for _, x := range xx {
+				got = append(got, x.ID)
+			}
+			assert.Equal(t, []int{it1.ID}, got)
+		}
+	})
+	t.Run("can list shared repos for guild", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		r1 := createRepo(t, st, UpdateOrCreateRepoParams{GuildID: "guild1", Owner: "alpha", Repo: "two"})
+		r2 := createRepo(t, st, UpdateOrCreateRepoParams{GuildID: "guild1", Owner: "alpha", Repo: "first"})
+		createRepo(t, st, UpdateOrCreateRepoParams{GuildID: "guild2"})
+		createRepo(t, st)
+		xx, err := st.ListReposForGuild("guild1")
+		if assert.NoError(t, err) {
+			var got []int
+			for _, x := range xx {
+				got = append(got, x.ID)
+			}
+			assert.Equal(t, []int{r2.ID, r1.ID}, got)
+		}
+	})
+	t.Run("should not list shared repos for user", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		r1 := createRepo(t, st, UpdateOrCreateRepoParams{UserID: "user1"})
+		createRepo(t, st, UpdateOrCreateRepoParams{UserID: "user1", GuildID: "guild1"})
+		xx, err := st.ListReposForUser("user1")
+		if assert.NoError(t, err) {
+			var got []int
+			for _, x := range xx {
+				got = append(got, x.ID)
+			}
+			assert.Equal(t, []int{r1.ID}, got)
+		}
+	})
+	t.Run("should update shared repo when added by another user", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		r1 := createRepo(t, st, UpdateOrCreateRepoParams{UserID: "user1", GuildID: "guild1"})
+		r2, created, err := st.UpdateOrCreateRepo(UpdateOrCreateRepoParams{
+			GuildID: "guild1",
+			Owner:   r1.Owner,
+			Repo:    r1.Repo,
+			Token:   "token",
+			UserID:  "user2",
+			Vendor:  r1.Vendor,
+		})
+		if assert.NoError(t, err) {
+			assert.False(t, created)
+			assert.Equal(t, r1.ID, r2.ID)
+			assert.Equal(t, "user2", r2.UserID)
+		}
+	})
+	t.Run("can update and get guild", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		g1, err := st.GetGuild("guild1")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "guild1", g1.ID)
+			assert.Empty(t, g1.Rules)
+		}
+		g1.AddRule(PermissionRule{Permission: permCreateIssues, RoleID: "role1"})
+		if err := st.UpdateGuild(g1); err != nil {
+			t.Fatal(err)
+		}
+		g2, err := st.GetGuild("guild1")
+		if assert.NoError(t, err) {
+			assert.Equal(t, g1, g2)
+		}
+	})
+	t.Run("converts reporter roles of older guilds into rules", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.UpdateGuild(&Guild{ID: "guild1", ReporterRoleIDs: []string{"role1"}}); err != nil {
+			t.Fatal(err)
+		}
+		g, err := st.GetGuild("guild1")
+		if assert.NoError(t, err) {
+			assert.Empty(t, g.ReporterRoleIDs)
+			assert.True(t, g.IsPermitted(permCreateIssues, 42, "user1", []string{"role1"}))
+		}
+	})
+	t.Run("can claim a submission only once", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		r := createRepo(t, st, UpdateOrCreateRepoParams{GuildID: "guild1"})
+		x1, err := st.CreateSubmission(CreateSubmissionParams{
+			GuildID: "guild1",
+			RepoID:  r.ID,
+			Title:   "title",
+			UserID:  "user1",
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		x2, err := st.ClaimSubmission(x1.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, x1.ID, x2.ID)
+		}
+		_, err = st.ClaimSubmission(x1.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		if assert.NoError(t, st.RestoreSubmission(x2)) {
+			_, err := st.GetSubmission(x1.ID)
+			assert.NoError(t, err)
+		}
+	})
+	t.Run("can create, get and delete a submission", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		r := createRepo(t, st, UpdateOrCreateRepoParams{GuildID: "guild1"})
+		x1, err := st.CreateSubmission(CreateSubmissionParams{
+			Body:    "body",
+			GuildID: "guild1",
+			Labels:  []string{"bug"},
+			RepoID:  r.ID,
+			Title:   "title",
+			UserID:  "user1",
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		x2, err := st.GetSubmission(x1.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "title", x2.Title)
+			assert.Equal(t, []string{"bug"}, x2.Labels)
+		}
+		if assert.NoError(t, st.DeleteSubmission(x1.ID)) {
+			_, err := st.GetSubmission(x1.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+		}
+	})
+	t.Run("can create, update, list and delete drafts", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		d1, err := st.CreateDraft("user1", 1, "first", []byte(`{"title":"first"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d2, err := st.CreateDraft("user1", 2, "second", []byte(`{"title":"second"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := st.CreateDraft("user2", 1, "other", []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+		d1.Title = "first changed"
+		if !assert.NoError(t, st.UpdateDraft(d1)) {
+			return
+		}
+		drafts, err := st.ListDraftsForUser("user1")
+		if assert.NoError(t, err) && assert.Len(t, drafts, 2) {
+			assert.Equal(t, d1.ID, drafts[0].ID)
+			assert.Equal(t, "first changed", drafts[0].Title)
+			assert.Equal(t, d2.ID, drafts[1].ID)
+		}
+		if assert.NoError(t, st.DeleteDraft(d1.ID)) {
+			_, err := st.GetDraft(d1.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+			drafts, err := st.ListDraftsForUser("user1")
+			if assert.NoError(t, err) {
+				assert.Len(t, drafts, 1)
+			}
+		}
+	})
+	t.Run("should return not found when updating unknown draft", func(t *testing.T) {
+		err := st.UpdateDraft(&Draft{ID: 999, UserID: "user1", Data: []byte("{}")})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("can update and get session", func(t *testing.T) {
+		s1 := &Session{ID: "abc", Data: []byte(`{"title":"x"}`), ExpiresAt: time.Now().Add(time.Hour).UTC()}
+		if assert.NoError(t, st.UpdateSession(s1)) {
+			s2, err := st.GetSession("abc")
+			if assert.NoError(t, err) {
+				assert.Equal(t, s1.Data, s2.Data)
+				assert.True(t, s1.ExpiresAt.Equal(s2.ExpiresAt))
+			}
+		}
+	})
+	t.Run("should return not found for expired session", func(t *testing.T) {
+		s := &Session{ID: "expired", Data: []byte("{}"), ExpiresAt: time.Now().Add(-time.Minute)}
+		if assert.NoError(t, st.UpdateSession(s)) {
+			_, err := st.GetSession("expired")
+			assert.ErrorIs(t, err, ErrNotFound)
+		}
+	})
+	t.Run("can delete expired sessions", func(t *testing.T) {
+		if err := st.DeleteAll(); err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		for _, s := range []*Session{
+			{ID: "1", Data: []byte("{}"), ExpiresAt: now.Add(-time.Minute)},
+			{ID: "2", Data: []byte("{}"), ExpiresAt: now.Add(time.Minute)},
+		} {
+			if err := st.UpdateSession(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		n, err := st.DeleteExpiredSessions(now)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, n)
+			_, err := st.GetSession("2")
+			assert.NoError(t, err)
+		}
+		if assert.NoError(t, st.DeleteSession("2")) {
+			_, err := st.GetSession("2")
+			assert.ErrorIs(t, err, ErrNotFound)
+		}
+	})
+	t.Run("should return empty user when not found", func(t *testing.T) {
+		u, err := st.GetUser("unknown")
+		if assert.NoError(t, err) {
+			assert.Equal(t, &User{ID: "unknown"}, u)
+		}
+	})
+	t.Run("can update and get user", func(t *testing.T) {
+		u1 := &User{ID: "user1", FavoriteRepoIDs: []int{3, 1}}
+		if assert.NoError(t, st.UpdateUser(u1)) {
+			u2, err := st.GetUser("user1")
+			if assert.NoError(t, err) {
+				assert.Equal(t, u1, u2)
+			}
+		}
+	})
+}
+
+func createIssue(t *testing.T, st *Storage, args ...CreateIssueParams) *Issue {
+	var arg CreateIssueParams
+	if len(args) > 0 {
+		arg = args[0]
+	}
+	if arg.RepoID == 0 {
+		arg.RepoID = createRepo(t, st).ID
+	}
+	if arg.UserID == "" {
+		arg.UserID = fmt.Sprintf("%s%d", fake.UserName(), rand.IntN(10_000))
+	}
+	if arg.MessageID == "" {
+		arg.MessageID = strconv.Itoa(rand.IntN(1_000_000))
+	}
+	if arg.Number == 0 {
+		arg.Number = rand.IntN(1_000)
+	}
+	if arg.Title == "" {
+		arg.Title = fake.Sentence()
+	}
+	if arg.URL == "" {
+		arg.URL = fmt.Sprintf("https://github.com/owner/repo/issues/%d", arg.Number)
+	}
+	it, err := st.CreateIssue(arg)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	return it
 }
 
 func createRepo(t *testing.T, st *Storage, args ...UpdateOrCreateRepoParams) *Repo {
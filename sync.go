@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	commentSyncInterval     = time.Minute
+	maxSyncedMessageLength  = 1800
+	syncedCommentMarker     = "<!-- issuebot:discord -->" // marks comments posted from Discord to prevent loops
+	syncedCommentSinceDrift = time.Minute                 // tolerance for clock differences with the vendor
+)
+
+// handleMessageCreate mirrors new messages in a thread as comments on the issues created from that thread.
+func (b *Bot) handleMessageCreate(mc *discordgo.MessageCreate) error {
+	if !b.guildInstall || mc.GuildID == "" || mc.Author == nil || mc.Author.Bot {
+		return nil
+	}
+	if mc.Type != discordgo.MessageTypeDefault && mc.Type != discordgo.MessageTypeReply {
+		return nil
+	}
+	if mc.Content == "" && len(mc.Attachments) == 0 && len(mc.MessageSnapshots) == 0 && mc.Poll == nil && len(mc.StickerItems) == 0 {
+		return nil
+	}
+	issues, err := b.st.ListIssuesForThread(mc.ChannelID)
+	if err != nil {
+		return err
+	}
+	for _, it := range issues {
+		if it.SyncStopped || it.MessageID == mc.ID {
+			continue
+		}
+		r, err := b.st.GetRepo(it.RepoID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		body := makeSyncedCommentBody(mc.Message, mc.GuildID, b.makeMentionNames(mc.GuildID, mc.Message, nil))
+		if err := b.api.createComment(r, it.Number, body); err != nil {
+			slog.Warn("Failed to sync message to issue", "url", it.URL, "messageID", mc.ID, "error", err)
+			continue
+		}
+		slog.Info("Message synced to issue", "url", it.URL, "messageID", mc.ID)
+	}
+	return nil
+}
+
+// StartCommentSync starts posting new comments from issues into the threads they were created from.
+// Comments are polled from the vendors in the background until the context is cancelled.
+func (b *Bot) StartCommentSync(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(commentSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := b.syncComments(); err != nil {
+					slog.Error("Failed to sync comments", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// syncComments posts new comments of all synced issues into their threads.
+func (b *Bot) syncComments() error {
+	issues, err := b.st.ListSyncedIssues()
+	if err != nil {
+		return err
+	}
+	for _, it := range issues {
+		if err := b.syncIssueComments(it); err != nil {
+			slog.Warn("Failed to sync comments of issue", "url", it.URL, "error", err)
+		}
+	}
+	return nil
+}
+
+// syncIssueComments posts new comments of an issue into its thread.
+// Syncing stops when the issue is closed or the repo or thread no longer exists.
+func (b *Bot) syncIssueComments(it *Issue) error {
+	r, err := b.st.GetRepo(it.RepoID)
+	if errors.Is(err, ErrNotFound) {
+		it.SyncStopped = true
+		return b.st.UpdateIssue(it)
+	} else if err != nil {
+		return err
+	}
+	startedAt := time.Now().UTC()
+	var since time.Time
+	if !it.CommentsSyncedAt.IsZero() {
+		since = it.CommentsSyncedAt.Add(-syncedCommentSinceDrift)
+	}
+	comments, err := b.api.listComments(r, it.Number, since)
+	if err != nil {
+		return err
+	}
+	if it.CommentsSyncedAt.IsZero() {
+		// existing comments have been posted before syncing started and are skipped
+		for _, c := range comments {
+			it.LastCommentID = max(it.LastCommentID, c.id)
+		}
+		it.CommentsSyncedAt = startedAt
+		return b.st.UpdateIssue(it)
+	}
+	var errSend error
+	for _, c := range comments {
+		if c.id <= it.LastCommentID {
+			continue
+		}
+		if !isSyncedComment(c.body) {
+			_, err := b.ds.ChannelMessageSendComplex(it.ThreadID, &discordgo.MessageSend{
+				Content:         makeSyncedMessage(r, it, c),
+				AllowedMentions: &discordgo.MessageAllowedMentions{},
+			})
+			if err != nil {
+				errSend = err
+				break
+			}
+		}
+		it.LastCommentID = c.id
+	}
+	if errSend != nil {
+		var restErr *discordgo.RESTError
+		if errors.As(errSend, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound {
+			slog.Info("Thread no longer exists. Comment sync stopped", "url", it.URL, "threadID", it.ThreadID)
+			it.SyncStopped = true
+			return b.st.UpdateIssue(it)
+		}
+		if err := b.st.UpdateIssue(it); err != nil {
+			return err
+		}
+		return errSend
+	}
+	x, err := b.api.getIssue(r, it.Number)
+	if err != nil {
+		return err
+	}
+	if x.state == issueStateClosed {
+		_, err := b.ds.ChannelMessageSend(
+			it.ThreadID,
+			fmt.Sprintf(":lock: Issue %s#%d has been closed. Comments are no longer synced.", r.Name(), it.Number),
+		)
+		if err != nil {
+			slog.Warn("Failed to post closed issue to thread", "threadID", it.ThreadID, "error", err)
+		}
+		it.SyncStopped = true
+		slog.Info("Issue closed. Comment sync stopped", "url", it.URL)
+	}
+	it.CommentsSyncedAt = startedAt
+	return b.st.UpdateIssue(it)
+}
+
+// makeSyncedCommentBody returns the body of an issue comment for a Discord message.
+func makeSyncedCommentBody(m *discordgo.Message, guildID string, names mentionNames) string {
+	var lines []string
+	if s := convertMessage(m, names); s != "" {
+		lines = append(lines, quoteMarkdown(s))
+	}
+	for _, a := range m.Attachments {
+		lines = append(lines, "- "+linkAttachment(newAttachment(a)))
+	}
+	messageURL := fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, m.ChannelID, m.ID)
+	return fmt.Sprintf(
+		"**%s** [commented on Discord](%s):\n\n%s\n\n%s",
+		m.Author.Username,
+		messageURL,
+		strings.Join(lines, "\n"),
+		syncedCommentMarker,
+	)
+}
+
+// isSyncedComment reports whether an issue comment was posted from Discord.
+func isSyncedComment(body string) bool {
+	return strings.Contains(body, syncedCommentMarker)
+}
+
+// makeSyncedMessage returns the content of a Discord message for an issue comment.
+func makeSyncedMessage(r *Repo, it *Issue, c vendorComment) string {
+	body := c.body
+	if runes := []rune(body); len(runes) > maxSyncedMessageLength {
+		body = string(runes[:maxSyncedMessageLength]) + "…"
+	}
+	return fmt.Sprintf(
+		"**%s** commented on [%s#%d](<%s>):\n> %s",
+		c.author,
+		r.Name(),
+		it.Number,
+		it.URL,
+		strings.ReplaceAll(body, "\n", "\n> "),
+	)
+}
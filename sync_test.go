@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeSyncedCommentBody(t *testing.T) {
+	m := &discordgo.Message{
+		ID:        "message1",
+		ChannelID: "thread1",
+		Author:    &discordgo.User{Username: "alice"},
+		Content:   "first line\nsecond line",
+		Attachments: []*discordgo.MessageAttachment{
+			{Filename: "log.txt", URL: "https://cdn.discordapp.com/log.txt"},
+		},
+	}
+	got := makeSyncedCommentBody(m, "guild1", mentionNames{})
+	want := "**alice** [commented on Discord](https://discord.com/channels/guild1/thread1/message1):\n\n" +
+		"> first line\n> second line\n- [log.txt](https://cdn.discordapp.com/log.txt) *(temporary link)*\n\n" +
+		syncedCommentMarker
+	assert.Equal(t, want, got)
+	assert.True(t, isSyncedComment(got))
+}
+
+func TestIsSyncedComment(t *testing.T) {
+	assert.True(t, isSyncedComment("text\n\n"+syncedCommentMarker))
+	assert.False(t, isSyncedComment("text"))
+}
+
+func TestMakeSyncedMessage(t *testing.T) {
+	r := &Repo{Owner: "owner", Repo: "repo", Vendor: gitHub}
+	it := &Issue{Number: 7, URL: "https://github.com/owner/repo/issues/7"}
+	t.Run("should quote every line", func(t *testing.T) {
+		got := makeSyncedMessage(r, it, vendorComment{author: "bob", body: "first\nsecond"})
+		want := "**bob** commented on [github.com/owner/repo#7](<https://github.com/owner/repo/issues/7>):\n> first\n> second"
+		assert.Equal(t, want, got)
+	})
+	t.Run("should truncate long comments", func(t *testing.T) {
+		body := make([]rune, maxSyncedMessageLength+100)
+		for i := range body {
+			body[i] = 'x'
+		}
+		got := makeSyncedMessage(r, it, vendorComment{author: "bob", body: string(body)})
+		assert.LessOrEqual(t, len([]rune(got)), 2000)
+		assert.Contains(t, got, "…")
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Default templates for new issues, which are used when neither the repo
+// nor the global configuration define a template.
+const (
+	defaultTitleTemplate = `{{.Title}}`
+	defaultBodyTemplate  = `
+{{- if .Basket}}
+{{- range $i, $m := .Basket}}{{if $i}}
+
+{{end}}{{quote $m.Content}}
+
+*Posted by **{{$m.AuthorName}}** on [Discord]({{$m.URL}})*
+{{- end}}
+{{- else if .MessageID}}{{quote .MessageContent}}
+
+*Originally posted by **{{.AuthorName}}** on {{if .MessageURL}}[Discord]({{.MessageURL}}){{else}}Discord{{end}}*
+{{- else}}
+{{- with .Description}}{{.}}
+
+{{end}}
+{{- with .MessageContent}}{{.}}
+
+{{end}}*Created by **{{.AuthorName}}** on Discord*
+{{- end}}
+{{- if or .Basket .MessageID}}{{with .Description}}
+
+{{.}}
+{{- end}}{{end}}`
+)
+
+// templateFuncs are the functions available in issue templates.
+var templateFuncs = template.FuncMap{
+	"quote": quoteMarkdown,
+}
+
+// IssueTemplates are Go templates for the title and body of new issues.
+// Empty templates fall back to the next level, i.e. from the repo to the global configuration
+// and from there to the defaults.
+type IssueTemplates struct {
+	Title       string `json:"title,omitempty"`
+	Body        string `json:"body,omitempty"`
+	Description string `json:"description,omitempty"`  // prefills the description when creating an issue
+	TitlePrefix string `json:"title_prefix,omitempty"` // prefixes suggested titles, e.g. "[Discord] "
+}
+
+// IsEmpty reports whether no templates are defined.
+func (t IssueTemplates) IsEmpty() bool {
+	return t == IssueTemplates{}
+}
+
+// Merge returns the templates with empty templates taken from fallback.
+func (t IssueTemplates) Merge(fallback IssueTemplates) IssueTemplates {
+	return IssueTemplates{
+		Title:       cmp.Or(t.Title, fallback.Title),
+		Body:        cmp.Or(t.Body, fallback.Body),
+		Description: cmp.Or(t.Description, fallback.Description),
+		TitlePrefix: cmp.Or(t.TitlePrefix, fallback.TitlePrefix),
+	}
+}
+
+// Validate reports whether the templates can be parsed and rendered.
+func (t IssueTemplates) Validate() error {
+	samples := []issueTemplateData{
+		{
+			AuthorID:         "1",
+			AuthorName:       "author",
+			ChannelID:        "2",
+			Description:      "description",
+			GuildID:          "3",
+			IssueType:        bugReport.Display(),
+			Labels:           bugReport.Labels(),
+			MessageContent:   "content",
+			MessageID:        "4",
+			MessageTimestamp: time.Now(),
+			MessageURL:       "https://discord.com/channels/3/2/4",
+			Title:            "title",
+			UserID:           "5",
+		},
+		{
+			AuthorName: "author",
+			Basket: []BasketMessage{
+				{AuthorID: "1", AuthorName: "author", ChannelID: "2", Content: "content", MessageID: "4"},
+			},
+			IssueType: neutralIssue.Display(),
+			Title:     "title",
+			UserID:    "5",
+		},
+		{
+			AuthorID:    "5",
+			AuthorName:  "author",
+			Description: "description",
+			IssueType:   featureRequest.Display(),
+			Title:       "title",
+			UserID:      "5",
+		},
+	}
+	for _, data := range samples {
+		title, body, err := t.Merge(defaultIssueTemplates).render(data)
+		if err != nil {
+			return err
+		}
+		if title == "" {
+			return errors.New("title template: renders an empty title")
+		}
+		if body == "" {
+			return errors.New("body template: renders an empty body")
+		}
+		if _, err := t.renderDescription(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderDescription returns the description rendered from the description template.
+func (t IssueTemplates) renderDescription(data issueTemplateData) (string, error) {
+	s, err := executeTemplate("description", t.Description, data)
+	if err != nil {
+		return "", fmt.Errorf("description template: %w", err)
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// render returns the title and body rendered from the templates.
+// The title is reduced to a single line.
+func (t IssueTemplates) render(data issueTemplateData) (string, string, error) {
+	title, err := executeTemplate("title", t.Title, data)
+	if err != nil {
+		return "", "", fmt.Errorf("title template: %w", err)
+	}
+	body, err := executeTemplate("body", t.Body, data)
+	if err != nil {
+		return "", "", fmt.Errorf("body template: %w", err)
+	}
+	title = strings.Join(strings.Fields(title), " ")
+	return title, strings.TrimSpace(body), nil
+}
+
+func executeTemplate(name, text string, data issueTemplateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+var defaultIssueTemplates = IssueTemplates{
+	Title: defaultTitleTemplate,
+	Body:  defaultBodyTemplate,
+}
+
+// issueTemplateData is the data available in issue templates.
+type issueTemplateData struct {
+	AuthorID         string // Discord user ID of the message author
+	AuthorName       string
+	Basket           []BasketMessage // messages of an issue created from several messages
+	ChannelID        string
+	Description      string // description entered when creating the issue
+	GuildID          string // empty for messages in direct messages
+	IssueType        string
+	Labels           []string
+	Member           *discordgo.Member // guild member who creates the issue, when known
+	MessageContent   string            // content of the message converted to markdown, or the attachment of /issue create
+	MessageID        string
+	MessageTimestamp time.Time
+	MessageURL       string // link to the message, empty for messages in direct messages
+	RepoID           int
+	ThreadID         string
+	Title            string // title entered when creating the issue
+	UserID           string // Discord user ID of the user creating the issue
+}
+
+func makeIssueTemplateData(s createIssueData, description string) issueTemplateData {
+	var messageURL string
+	if s.guildID != "" {
+		messageURL = fmt.Sprintf("https://discord.com/channels/%s/%s/%s", s.guildID, s.channelID, s.messageID)
+	}
+	return issueTemplateData{
+		AuthorID:         s.authorID,
+		AuthorName:       s.authorName,
+		Basket:           s.basket,
+		ChannelID:        s.channelID,
+		Description:      description,
+		GuildID:          s.guildID,
+		IssueType:        s.issueType.Display(),
+		Labels:           s.issueType.Labels(),
+		Member:           s.member,
+		MessageContent:   s.messageContent,
+		MessageID:        s.messageID,
+		MessageTimestamp: s.messageTimestamp,
+		MessageURL:       messageURL,
+		RepoID:           s.repoID,
+		ThreadID:         s.threadID,
+		Title:            s.title,
+		UserID:           s.userID,
+	}
+}
+
+// issueTemplates returns the templates for new issues of a repo.
+func (b *Bot) issueTemplates(r *Repo) IssueTemplates {
+	return r.Templates.Merge(b.templates).Merge(defaultIssueTemplates)
+}
+
+// prefixTitle returns a title with the title prefix, truncated to the max length of titles.
+func (t IssueTemplates) prefixTitle(title string) string {
+	prefix := strings.TrimSpace(t.TitlePrefix)
+	if prefix != "" && !strings.HasPrefix(title, prefix) {
+		title = prefix + " " + title
+	}
+	return truncateAtWord(title, maxTitleLength)
+}
+
+// prefillIssueDetails returns the session with the title and description prefilled
+// when they have not been entered yet.
+// The title is suggested from the message with the title prefix
+// and the description is rendered from the description template.
+func prefillIssueDetails(t IssueTemplates, s createIssueData) (createIssueData, error) {
+	if s.hasPreview {
+		return s, nil
+	}
+	if s.title == "" {
+		content := s.messageContent
+		if len(s.basket) > 0 {
+			content = s.basket[0].Content
+		}
+		if title := suggestTitle(content, maxTitleLength); title != "" {
+			s.title = t.prefixTitle(title)
+		}
+	}
+	if s.description == "" && t.Description != "" {
+		description, err := t.renderDescription(makeIssueTemplateData(s, ""))
+		if err != nil {
+			return s, err
+		}
+		s.description = description
+	}
+	return s, nil
+}
+
+// makeCreateIssueParams returns the parameters for creating an issue from the data of a session.
+// Title and body are rendered from the templates. The title of the session is used
+// when the title template renders an empty title.
+func makeCreateIssueParams(t IssueTemplates, s createIssueData, description string) (createIssueParams, error) {
+	title, body, err := t.render(makeIssueTemplateData(s, description))
+	if err != nil {
+		return createIssueParams{}, err
+	}
+	arg := createIssueParams{
+		body:   body,
+		labels: s.issueType.Labels(),
+		title:  cmp.Or(title, s.title),
+	}
+	return arg, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeCreateIssueParams(t *testing.T) {
+	s := createIssueData{
+		authorID:         "42",
+		authorName:       "alice",
+		channelID:        "c1",
+		guildID:          "g1",
+		issueType:        featureRequest,
+		messageContent:   "first\n\nsecond",
+		messageID:        "m1",
+		messageTimestamp: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		title:            "title",
+	}
+	t.Run("can render default templates", func(t *testing.T) {
+		got, err := makeCreateIssueParams(defaultIssueTemplates, s, "description")
+		require.NoError(t, err)
+		assert.Equal(t, "title", got.title)
+		assert.Equal(t, []string{"enhancement"}, got.labels)
+		assert.Equal(
+			t,
+			"> first\n>\n> second\n\n*Originally posted by **alice** on [Discord](https://discord.com/channels/g1/c1/m1)*\n\ndescription",
+			got.body,
+		)
+	})
+	t.Run("can render default templates for direct messages", func(t *testing.T) {
+		s := s
+		s.guildID = ""
+		got, err := makeCreateIssueParams(defaultIssueTemplates, s, "")
+		require.NoError(t, err)
+		assert.Equal(t, "> first\n>\n> second\n\n*Originally posted by **alice** on Discord*", got.body)
+	})
+	t.Run("can render default templates for commands", func(t *testing.T) {
+		s := createIssueData{
+			authorName:     "alice",
+			issueType:      bugReport,
+			messageContent: "![screenshot.png](https://cdn.discordapp.com/screenshot.png) *(temporary link)*",
+			title:          "title",
+		}
+		got, err := makeCreateIssueParams(defaultIssueTemplates, s, "description")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bug"}, got.labels)
+		assert.Equal(
+			t,
+			"description\n\n![screenshot.png](https://cdn.discordapp.com/screenshot.png) *(temporary link)*\n\n*Created by **alice** on Discord*",
+			got.body,
+		)
+		s.messageContent = ""
+		got, err = makeCreateIssueParams(defaultIssueTemplates, s, "")
+		require.NoError(t, err)
+		assert.Equal(t, "*Created by **alice** on Discord*", got.body)
+	})
+	t.Run("can render custom templates", func(t *testing.T) {
+		tmpl := IssueTemplates{
+			Title: "[{{.IssueType}}] {{.Title}}",
+			Body: "Reported via Discord by <{{.AuthorID}}> on {{.MessageTimestamp.Format \"2006-01-02\"}}\n" +
+				"<details>\n\n{{.MessageContent}}\n\n</details>",
+		}
+		got, err := makeCreateIssueParams(tmpl, s, "")
+		require.NoError(t, err)
+		assert.Equal(t, "[feature request] title", got.title)
+		assert.Equal(t, "Reported via Discord by <42> on 2025-03-01\n<details>\n\nfirst\n\nsecond\n\n</details>", got.body)
+	})
+	t.Run("should reduce title to one line", func(t *testing.T) {
+		got, err := makeCreateIssueParams(IssueTemplates{Title: "{{.Title}}\n\n  suffix  ", Body: "x"}, s, "")
+		require.NoError(t, err)
+		assert.Equal(t, "title suffix", got.title)
+	})
+	t.Run("should fall back to title of session when rendered title is empty", func(t *testing.T) {
+		got, err := makeCreateIssueParams(IssueTemplates{Title: "{{.Description}}", Body: "x"}, s, "")
+		require.NoError(t, err)
+		assert.Equal(t, "title", got.title)
+	})
+	t.Run("should return error when template fails", func(t *testing.T) {
+		_, err := makeCreateIssueParams(IssueTemplates{Title: "{{.Unknown}}", Body: "x"}, s, "")
+		assert.Error(t, err)
+	})
+}
+
+func TestIssueTemplatesValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		t     IssueTemplates
+		valid bool
+	}{
+		{"empty templates use defaults", IssueTemplates{}, true},
+		{"default templates", defaultIssueTemplates, true},
+		{"custom templates", IssueTemplates{Title: "[Discord] {{.Title}}", Body: "{{quote .MessageContent}}"}, true},
+		{"all fields", IssueTemplates{
+			Body: "{{.AuthorID}} {{.AuthorName}} {{.ChannelID}} {{.Description}} {{.GuildID}} {{.IssueType}} " +
+				"{{.Labels}} {{.MessageContent}} {{.MessageID}} {{.MessageTimestamp}} {{.MessageURL}} " +
+				"{{.RepoID}} {{.ThreadID}} {{.Title}} {{.UserID}} {{range .Basket}}{{.URL}}{{end}}",
+		}, true},
+		{"syntax error in title", IssueTemplates{Title: "{{.Title"}, false},
+		{"syntax error in body", IssueTemplates{Body: "{{if .Title}}"}, false},
+		{"unknown field", IssueTemplates{Body: "{{.Unknown}}"}, false},
+		{"unknown function", IssueTemplates{Body: "{{upper .Title}}"}, false},
+		{"empty title", IssueTemplates{Title: "{{if false}}x{{end}}"}, false},
+		{"empty body", IssueTemplates{Body: "  {{/* nothing */}}  "}, false},
+		{"description template", IssueTemplates{Description: "Steps to reproduce for {{.Title}}:"}, true},
+		{"syntax error in description", IssueTemplates{Description: "{{.Title"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.t.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPrefillIssueDetails(t *testing.T) {
+	s := createIssueData{
+		authorName:     "alice",
+		issueType:      bugReport,
+		messageContent: "**The app** crashes\nwhen I click",
+	}
+	t.Run("can prefill title and description", func(t *testing.T) {
+		tmpl := IssueTemplates{Description: "Reported by {{.AuthorName}} as {{.IssueType}}", TitlePrefix: "[Discord]"}
+		got, err := prefillIssueDetails(tmpl, s)
+		require.NoError(t, err)
+		assert.Equal(t, "[Discord] The app crashes", got.title)
+		assert.Equal(t, "Reported by alice as bug report", got.description)
+	})
+	t.Run("can prefill title from first message of basket", func(t *testing.T) {
+		s := createIssueData{basket: []BasketMessage{{Content: "first"}, {Content: "second"}}}
+		got, err := prefillIssueDetails(IssueTemplates{}, s)
+		require.NoError(t, err)
+		assert.Equal(t, "first", got.title)
+		assert.Equal(t, "", got.description)
+	})
+	t.Run("should not change details which were entered", func(t *testing.T) {
+		s := s
+		s.title = "title"
+		s.description = "description"
+		got, err := prefillIssueDetails(IssueTemplates{Description: "template", TitlePrefix: "[Discord]"}, s)
+		require.NoError(t, err)
+		assert.Equal(t, "title", got.title)
+		assert.Equal(t, "description", got.description)
+	})
+	t.Run("should not prefill after preview was shown", func(t *testing.T) {
+		s := s
+		s.hasPreview = true
+		got, err := prefillIssueDetails(IssueTemplates{Description: "template"}, s)
+		require.NoError(t, err)
+		assert.Equal(t, s, got)
+	})
+	t.Run("should keep prefixed title within max length", func(t *testing.T) {
+		s := s
+		s.messageContent = strings.Repeat("word ", 100)
+		got, err := prefillIssueDetails(IssueTemplates{TitlePrefix: "[Discord]"}, s)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(got.title, "[Discord] word"))
+		assert.LessOrEqual(t, utf8.RuneCountInString(got.title), maxTitleLength)
+	})
+}
+
+func TestIssueTemplatesMerge(t *testing.T) {
+	repo := IssueTemplates{Title: "repo"}
+	global := IssueTemplates{Title: "global", Body: "global"}
+	assert.Equal(t, IssueTemplates{Title: "repo", Body: "global"}, repo.Merge(global))
+	assert.Equal(t, global, IssueTemplates{}.Merge(global))
+}
+
+func TestIssueTemplatesPrefixTitle(t *testing.T) {
+	tmpl := IssueTemplates{TitlePrefix: "[Discord]"}
+	assert.Equal(t, "[Discord] title", tmpl.prefixTitle("title"))
+	assert.Equal(t, "[Discord] title", tmpl.prefixTitle("[Discord] title"))
+	assert.Equal(t, "title", IssueTemplates{}.prefixTitle("title"))
+}